@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1" //nolint:gosec // required by the Have I Been Pwned data format
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Generated passwords that are found in the breach data are regenerated up to
+// this many times.
+const maxBreachRegenerations = 10
+
+// Once the binary search has narrowed a text file down to this many bytes, the
+// remainder is scanned line by line.
+const breachScanSize = 4096
+
+// Size of each record in a binary breach index: a raw SHA-1 hash.
+const breachRecordSize = sha1.Size
+
+// File extension of binary breach indexes.
+const BREACH_INDEX_EXT = ".bin"
+
+// Returns the upper-case hex SHA-1 hash of the password, as used by Have I Been
+// Pwned.
+func pwnedHash(password string) string {
+	h := sha1.Sum([]byte(password)) //nolint:gosec // required by the data format
+	return strings.ToUpper(hex.EncodeToString(h[:]))
+}
+
+// Looks up a password in offline Have I Been Pwned data, without any network
+// access. p can be any of:
+//
+//   - a text file of "HASH:COUNT" lines sorted by hash, as downloaded with the
+//     ordered-by-hash option
+//   - a directory of range files named after the first 5 characters of the
+//     hash, each containing sorted "SUFFIX:COUNT" lines
+//   - a binary index of sorted raw SHA-1 hashes (with the .bin extension), as
+//     created with the breach-index subcommand; it has no counts, so found
+//     passwords have a count of 1
+//
+// Returns the number of times the password was seen in breaches, or 0 if it
+// wasn't found.
+func breachCount(p string, password string) (int64, error) {
+	hash := pwnedHash(password)
+
+	fi, err := os.Stat(p)
+	if err != nil {
+		return 0, fmt.Errorf("breach data not readable at %v: %v", p, err.Error())
+	}
+
+	if fi.IsDir() {
+		f, err := os.Open(filepath.Join(p, hash[:5]+".txt"))
+		if os.IsNotExist(err) {
+			return 0, nil
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to open breach range file: %v", err.Error())
+		}
+		defer f.Close()
+
+		return searchSortedLines(f, hash[5:])
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open breach data %v: %v", p, err.Error())
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(p), BREACH_INDEX_EXT) {
+		return searchIndex(f, fi.Size(), hash)
+	}
+
+	return searchSortedLines(f, hash)
+}
+
+// Binary searches a file of "KEY:COUNT" lines sorted by key, and returns the
+// count for the key, or 0 if the key isn't present.
+func searchSortedLines(f *os.File, key string) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat breach data: %v", err.Error())
+	}
+
+	// lo is always the start of a line whose key is less than the key (or the
+	// start of the file)
+	lo, hi := int64(0), fi.Size()
+	for hi-lo > breachScanSize {
+		mid := lo + (hi-lo)/2
+
+		// skip the partial line at mid
+		r := bufio.NewReader(io.NewSectionReader(f, mid, fi.Size()-mid))
+		skipped, err := r.ReadString('\n')
+		if err != nil {
+			hi = mid
+			continue
+		}
+
+		line, err := r.ReadString('\n')
+		if err != nil && line == "" {
+			hi = mid
+			continue
+		}
+
+		if lineKey(line) < key {
+			lo = mid + int64(len(skipped))
+		} else {
+			hi = mid
+		}
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(f, lo, fi.Size()-lo))
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			k := lineKey(line)
+			if k == key {
+				_, count, _ := strings.Cut(strings.TrimSpace(line), ":")
+				n, err := strconv.ParseInt(count, 10, 64)
+				if err != nil {
+					// the key is present, even if the count can't be read
+					return 1, nil
+				}
+
+				return n, nil
+			}
+
+			if k > key {
+				return 0, nil
+			}
+		}
+
+		if err == io.EOF {
+			return 0, nil
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to read breach data: %v", err.Error())
+		}
+	}
+}
+
+// Returns the upper-case key of a "KEY:COUNT" line.
+func lineKey(line string) string {
+	k, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(k)
+}
+
+// Binary searches a binary index of sorted raw SHA-1 hashes. Returns 1 if the
+// hash is present, or 0 otherwise.
+func searchIndex(f *os.File, size int64, hash string) (int64, error) {
+	if size%breachRecordSize != 0 {
+		return 0, fmt.Errorf("breach index has an invalid size of %v bytes", size)
+	}
+
+	target, err := hex.DecodeString(hash)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hash: %v", err.Error())
+	}
+
+	n := int(size / breachRecordSize)
+	record := make([]byte, breachRecordSize)
+	var readErr error
+	i := sort.Search(n, func(i int) bool {
+		if _, err := f.ReadAt(record, int64(i)*breachRecordSize); err != nil {
+			readErr = err
+			return true
+		}
+
+		return bytes.Compare(record, target) >= 0
+	})
+
+	if readErr != nil {
+		return 0, fmt.Errorf("failed to read breach index: %v", readErr.Error())
+	}
+
+	if i < n {
+		if _, err := f.ReadAt(record, int64(i)*breachRecordSize); err != nil {
+			return 0, fmt.Errorf("failed to read breach index: %v", err.Error())
+		}
+
+		if bytes.Equal(record, target) {
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// Looks up a password in the configured breach data. Returns 0 if no breach
+// data is configured.
+func (app *App) breached(password string) (int64, error) {
+	if app.conf.BreachFile == "" {
+		return 0, nil
+	}
+
+	return breachCount(app.conf.BreachFile, password)
+}
+
+// Runs the breach-index subcommand, which converts a sorted "HASH:COUNT" text
+// file into a binary index that is a fraction of the size and faster to
+// search. Returns the process exit code.
+func breachIndex(args []string) int {
+	fs := flag.NewFlagSet("breach-index", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %v breach-index <sorted-hashes.txt> <index%v>\n", os.Args[0], BREACH_INDEX_EXT)
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	err := writeBreachIndex(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// Converts a sorted "HASH:COUNT" text file into a binary index.
+func writeBreachIndex(in string, out string) error {
+	src, err := os.Open(in)
+	if err != nil {
+		return fmt.Errorf("failed to open %v: %v", in, err.Error())
+	}
+	defer src.Close()
+
+	dst, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("failed to create %v: %v", out, err.Error())
+	}
+	defer dst.Close()
+
+	w := bufio.NewWriter(dst)
+	scanner := bufio.NewScanner(src)
+	prev := make([]byte, breachRecordSize)
+	lines := 0
+	for scanner.Scan() {
+		k := lineKey(scanner.Text())
+		if k == "" {
+			continue
+		}
+
+		record, err := hex.DecodeString(k)
+		if err != nil || len(record) != breachRecordSize {
+			return fmt.Errorf("line %v of %v is not a SHA-1 hash", lines+1, in)
+		}
+
+		if lines > 0 && bytes.Compare(record, prev) < 0 {
+			return fmt.Errorf("%v is not sorted by hash at line %v", in, lines+1)
+		}
+
+		if _, err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write %v: %v", out, err.Error())
+		}
+
+		copy(prev, record)
+		lines++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %v: %v", in, err.Error())
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %v: %v", out, err.Error())
+	}
+
+	Logf("wrote %v hashes to %v", lines, out)
+
+	return nil
+}
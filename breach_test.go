@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Breached passwords for the tests, and their counts. There are enough of them
+// that the binary search has to narrow down more than breachScanSize bytes.
+func testBreaches() map[string]int64 {
+	breaches := map[string]int64{}
+	for i := 0; i < 2000; i++ {
+		breaches[fmt.Sprintf("breached %v", i)] = int64(i + 1)
+	}
+
+	return breaches
+}
+
+// Returns the hashes of the passwords sorted, and which password each hash
+// belongs to.
+func sortedHashes(breaches map[string]int64) ([]string, map[string]string) {
+	hashes := make([]string, 0, len(breaches))
+	passwords := map[string]string{}
+	for p := range breaches {
+		h := pwnedHash(p)
+		hashes = append(hashes, h)
+		passwords[h] = p
+	}
+
+	sort.Strings(hashes)
+
+	return hashes, passwords
+}
+
+// Returns the lines of a "KEY:COUNT" file for the hashes, with the first
+// prefix characters of each hash cut off.
+func breachLines(hashes []string, passwords map[string]string, breaches map[string]int64, prefix int, eol string, lower bool) string {
+	sb := new(strings.Builder)
+	for _, h := range hashes {
+		k := h[prefix:]
+		if lower {
+			k = strings.ToLower(k)
+		}
+
+		fmt.Fprintf(sb, "%v:%v%v", k, breaches[passwords[h]], eol)
+	}
+
+	return sb.String()
+}
+
+func writeTestFile(t *testing.T, p string, data string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(p, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Checks that every breached password is found with its count, including the
+// ones whose hashes are first and last in the data, and that passwords that
+// aren't breached aren't found.
+func checkBreachData(t *testing.T, p string, hashes []string, passwords map[string]string, breaches map[string]int64, counts bool) {
+	t.Helper()
+
+	for _, h := range hashes {
+		pw := passwords[h]
+		want := breaches[pw]
+		if !counts {
+			want = 1
+		}
+
+		n, err := breachCount(p, pw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != want {
+			t.Errorf("%q (%v): got count %v, want %v", pw, h, n, want)
+		}
+	}
+
+	for _, pw := range []string{"not breached", "", "breached 2000"} {
+		n, err := breachCount(p, pw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 0 {
+			t.Errorf("%q: got count %v, want 0", pw, n)
+		}
+	}
+}
+
+func TestBreachCountFile(t *testing.T) {
+	breaches := testBreaches()
+	hashes, passwords := sortedHashes(breaches)
+
+	tests := []struct {
+		name  string
+		eol   string
+		lower bool
+	}{
+		{"LF", "\n", false},
+		{"CRLF", "\r\n", false},
+		{"lower case", "\n", true},
+		{"CRLF and lower case", "\r\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "pwned.txt")
+			writeTestFile(t, p, breachLines(hashes, passwords, breaches, 0, tt.eol, tt.lower))
+			checkBreachData(t, p, hashes, passwords, breaches, true)
+
+			// without a trailing newline, the last line is still found
+			data := breachLines(hashes, passwords, breaches, 0, tt.eol, tt.lower)
+			writeTestFile(t, p, strings.TrimSuffix(data, tt.eol))
+			checkBreachData(t, p, hashes, passwords, breaches, true)
+		})
+	}
+
+	// a file that's smaller than breachScanSize is only scanned
+	small := map[string]int64{"one": 1, "two": 2, "three": 3}
+	hashes, passwords = sortedHashes(small)
+	p := filepath.Join(t.TempDir(), "pwned.txt")
+	writeTestFile(t, p, breachLines(hashes, passwords, small, 0, "\n", false))
+	checkBreachData(t, p, hashes, passwords, small, true)
+
+	// an empty file has nothing in it
+	writeTestFile(t, p, "")
+	if n, err := breachCount(p, "one"); n != 0 || err != nil {
+		t.Errorf("empty file: got %v, %v", n, err)
+	}
+}
+
+func TestBreachCountRangeDir(t *testing.T) {
+	breaches := testBreaches()
+	hashes, passwords := sortedHashes(breaches)
+
+	dir := t.TempDir()
+	ranges := map[string][]string{}
+	for _, h := range hashes {
+		ranges[h[:5]] = append(ranges[h[:5]], h)
+	}
+
+	for prefix, hs := range ranges {
+		writeTestFile(t, filepath.Join(dir, prefix+".txt"), breachLines(hs, passwords, breaches, 5, "\r\n", false))
+	}
+
+	checkBreachData(t, dir, hashes, passwords, breaches, true)
+
+	// a password whose range file is missing isn't breached
+	pw := "missing range"
+	if _, ok := ranges[pwnedHash(pw)[:5]]; ok {
+		t.Fatalf("%q has a range file", pw)
+	}
+
+	if n, err := breachCount(dir, pw); n != 0 || err != nil {
+		t.Errorf("missing range file: got %v, %v", n, err)
+	}
+}
+
+func TestBreachIndex(t *testing.T) {
+	breaches := testBreaches()
+	hashes, passwords := sortedHashes(breaches)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "pwned.txt")
+	index := filepath.Join(dir, "pwned"+BREACH_INDEX_EXT)
+	writeTestFile(t, src, breachLines(hashes, passwords, breaches, 0, "\r\n", true))
+	if err := writeBreachIndex(src, index); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Size() != int64(len(hashes)*breachRecordSize) {
+		t.Errorf("index has %v bytes, want %v", fi.Size(), len(hashes)*breachRecordSize)
+	}
+
+	checkBreachData(t, index, hashes, passwords, breaches, false)
+
+	// the input must be sorted and contain only hashes
+	writeTestFile(t, src, breachLines([]string{hashes[1], hashes[0]}, passwords, breaches, 0, "\n", false))
+	if err := writeBreachIndex(src, index); err == nil {
+		t.Error("expected an error for unsorted input")
+	}
+
+	writeTestFile(t, src, "not a hash:1\n")
+	if err := writeBreachIndex(src, index); err == nil {
+		t.Error("expected an error for input that isn't hashes")
+	}
+
+	// an index with a partial record is rejected
+	writeTestFile(t, index, strings.Repeat("x", breachRecordSize+1))
+	if _, err := breachCount(index, "breached 1"); err == nil {
+		t.Error("expected an error for an index with a partial record")
+	}
+}
+
+// Breached passwords always get the lowest score.
+func TestCheckPasswordBreached(t *testing.T) {
+	app := testApp()
+	app.dict = testDictionary()
+	app.conf.BreachFile = filepath.Join(t.TempDir(), "pwned.txt")
+
+	breaches := map[string]int64{"correcthorsebatterystaple": 42}
+	hashes, passwords := sortedHashes(breaches)
+	writeTestFile(t, app.conf.BreachFile, breachLines(hashes, passwords, breaches, 0, "\n", false))
+
+	s, err := app.checkPassword("correcthorsebatterystaple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Breaches != 42 || s.Score != 0 {
+		t.Errorf("got %v breaches and score %v, want 42 and 0", s.Breaches, s.Score)
+	}
+}
@@ -6,8 +6,9 @@ import (
 	"os"
 	"strconv"
 
+	"unicode/utf8"
+
 	"github.com/atotto/clipboard"
-	dice "github.com/charles-m-knox/go-dicewarelib"
 	"github.com/pwiecz/go-fltk"
 )
 
@@ -20,10 +21,17 @@ func (app *App) setCallbacks() {
 	app.ui.menu.AddEx("Generate 1", fltk.CTRL+'r', app.gen, 0)
 	app.ui.menu.AddEx("Generate 2", fltk.CTRL+fltk.ENTER_KEY, app.gen, 0)
 	app.ui.menu.AddEx("Help", fltk.F1, app.help, 0)
+	app.ui.menu.AddEx("Add Entropy", fltk.CTRL+'e', app.entropyDialog, 0)
+	app.ui.menu.AddEx("Check Password", fltk.CTRL+'k', app.checkWindow, 0)
+	app.ui.menu.AddEx("Breach Data", fltk.CTRL+'b', app.chooseBreachFile, 0)
+	app.ui.menu.AddEx("Incognito", fltk.CTRL+fltk.SHIFT+'n', app.enterIncognito, 0)
+	app.ui.menu.AddEx("NIST Report", fltk.CTRL+fltk.SHIFT+'a', app.nistWindow, 0)
+	app.ui.menu.AddEx("Share Settings", fltk.CTRL+fltk.SHIFT+'s', app.shareDialog, 0)
 
-	app.darkCB()
+	app.themeCB()
 	app.genCB()
-	app.extraCB()
+	app.mixCB()
+	app.langCB()
 	app.sepCB()
 	app.minCB()
 	app.maxCB()
@@ -31,38 +39,99 @@ func (app *App) setCallbacks() {
 }
 
 func (app *App) help() {
-	fltk.MessageBox("Help", "Generates relatively secure passwords that meet most website requirements.\nKeyboard shortcuts:\nCtrl+Shift+C: Copy to clipboard\nCtrl+R and Ctrl+Enter: Generate new password\nCtrl+Q: Quit\nF1: Help")
+	fltk.MessageBox("Help", "Generates relatively secure passwords that meet most website requirements.\nKeyboard shortcuts:\nCtrl+Shift+C: Copy to clipboard\nCtrl+R and Ctrl+Enter: Generate new password\nCtrl+E: Add your own entropy for this session\nCtrl+K: Check the strength of an existing password\nCtrl+B: Choose offline breach data to check passwords against\nCtrl+Shift+N: Incognito mode, which saves nothing for the rest of this session\nCtrl+Shift+A: Check the current password against NIST SP 800-63B\nCtrl+Shift+S: Share settings as a code or import shared settings\nCtrl+Q: Quit\nF1: Help")
 }
 
-// Enables/disables dark mode.
-func (app *App) darkCB() {
-	app.ui.dark.SetCallback(func() {
-		app.conf.DarkMode = !app.conf.DarkMode
-		if !app.ui.darkModeChanged {
-			fltk.MessageBox("App Restart Required", "In order for this to take effect, the theme change won't take effect until the application is restarted.")
+// Populates the mix presets, and changes how words are drawn from the word
+// lists when a preset is chosen or a mix is typed in.
+func (app *App) mixCB() {
+	for _, preset := range mixPresets {
+		p := preset
+		app.ui.mix.MenuButton().Add(p, func() { app.setMix(p) })
+	}
+
+	app.ui.mix.SetCallback(func() { app.setMix(app.ui.mix.Value()) })
+}
 
-			app.ui.darkModeChanged = true
+// Validates and applies a new mix, loading or unloading the extended word list
+// as needed.
+func (app *App) setMix(v string) {
+	m, err := parseMix(v)
+	if err != nil {
+		app.ui.log.SetValue(err.Error())
+		return
+	}
+
+	if m.needsComplex() && !app.mix.needsComplex() {
+		confirmed := fltk.ChoiceDialog("Loading the extra words into memory will increase the RAM usage of this program. Proceed?", "Yes", "Cancel")
+		if confirmed == 1 {
+			app.ui.mix.SetValue(app.conf.Mix)
+			return
 		}
-		app.ui.dark.SetValue(app.conf.DarkMode)
-	})
+	}
+
+	app.conf.Mix = m.String()
+	app.settingsChanged()
+	app.initDice()
+	app.ui.mix.SetValue(app.conf.Mix)
+	app.ui.log.SetValue(fmt.Sprintf("Drawing words with mix %v from %v simple and %v extended words", app.mix, len(app.words.Simple), len(app.words.Complex)))
 }
 
-// Enables/disables extra word usage.
-func (app *App) extraCB() {
-	app.ui.extra.SetCallback(func() {
-		if !app.conf.Extra {
-			confirmed := fltk.ChoiceDialog("Loading the extra words into memory will increase the RAM usage of this program. Proceed?", "Yes", "Cancel")
-			if confirmed == 1 {
-				app.ui.extra.SetValue(app.conf.Extra)
+// Populates the language selector with all available languages, and switches
+// the word lists when a different language is selected.
+func (app *App) langCB() {
+	for i, lang := range app.langs {
+		name := lang.Name
+		app.ui.lang.Add(name, func() {
+			if name == app.conf.Lang {
 				return
 			}
+
+			app.conf.Lang = name
+			app.settingsChanged()
+			app.initDice()
+			app.ui.mix.SetValue(app.conf.Mix)
+			app.ui.log.SetValue(fmt.Sprintf("Loaded %v simple and %v extended words for language %v", len(app.words.Simple), len(app.words.Complex), app.lang))
+		})
+
+		if name == app.lang {
+			app.ui.lang.SetValue(i)
 		}
-		app.conf.Extra = !app.conf.Extra
+	}
+}
 
-		// fltk.MessageBox("App Restart Required", "In order for this to take effect, this application must be restarted.")
-		app.ui.extra.SetValue(app.conf.Extra)
-		app.initDice()
-	})
+// Lets the user choose the offline breach data that passwords are checked
+// against, or stop checking against it.
+func (app *App) chooseBreachFile() {
+	if err := app.checkLocked("breachFile"); err != nil {
+		fltk.MessageBox("Locked", err.Error())
+		return
+	}
+
+	if app.conf.BreachFile != "" {
+		disable := fltk.ChoiceDialog(fmt.Sprintf("Passwords are checked against the breach data at %v.", app.conf.BreachFile), "Choose Other...", "Stop Checking")
+		if disable == 1 {
+			app.conf.BreachFile = ""
+			app.settingsChanged()
+			app.ui.log.SetValue("Passwords are no longer checked against breach data")
+			return
+		}
+	}
+
+	p, ok := fltk.ChooseFile("Choose a sorted SHA-1 hash file or .bin index", "*", app.conf.BreachFile, false)
+	if !ok || p == "" {
+		return
+	}
+
+	// make sure that the file can actually be searched before using it
+	if _, err := breachCount(p, ""); err != nil {
+		fltk.MessageBox("Error", err.Error())
+		return
+	}
+
+	app.conf.BreachFile = p
+	app.settingsChanged()
+	app.ui.log.SetValue(fmt.Sprintf("Checking passwords against the breach data at %v", p))
 }
 
 // Copies the last-shown output value to the clipboard.
@@ -83,16 +152,16 @@ func (app *App) copy() {
 
 // A standalone function that generates passwords according to the requirements.
 func (app *App) gen() {
-	r := dice.GeneratePassword(
-		&app.words,
-		app.conf.WordCount,
-		app.conf.Separator,
-		app.conf.MaxLen,
-		app.conf.MinLen,
-		app.conf.Extra,
-	)
+	r, err := app.generate()
+	if err != nil {
+		app.ui.out.SetValue("")
+		app.ui.log.SetValue(fmt.Sprintf("Failed to generate password: %v", err.Error()))
+		return
+	}
+
 	app.ui.out.SetValue(r)
-	app.ui.log.SetValue(fmt.Sprintf("Currently generated password length: %v", len(r)))
+	app.ui.log.SetValue(fmt.Sprintf("Currently generated password length: %v, entropy: %.1f bits<br>Average time to crack:<br>%v", utf8.RuneCountInString(r), app.entropy(), crackTimesHTML(app.crackTimes())))
+	app.showViolations()
 }
 
 // Generates passwords according to the requirements when the "Generate" button
@@ -103,7 +172,17 @@ func (app *App) genCB() {
 
 // Updates the separator when the user changes the separator input field.
 func (app *App) sepCB() {
-	app.ui.sep.SetCallback(func() { app.conf.Separator = app.ui.sep.Value() })
+	app.ui.sep.SetCallback(func() {
+		c := *app.conf
+		c.Separator = app.ui.sep.Value()
+		if err := validateField(&c, "separator"); err != nil {
+			app.ui.log.SetValue(err.Error())
+			return
+		}
+
+		app.conf.Separator = c.Separator
+		app.settingsChanged()
+	})
 }
 
 // Updates the min length when the user changes the min input field.
@@ -117,7 +196,15 @@ func (app *App) minCB() {
 		if err != nil {
 			return
 		}
-		app.conf.MinLen = int(i)
+		c := *app.conf
+		c.MinLen = int(i)
+		if err := validateField(&c, "minLen"); err != nil {
+			app.ui.log.SetValue(err.Error())
+			return
+		}
+
+		app.conf.MinLen = c.MinLen
+		app.settingsChanged()
 	})
 }
 
@@ -132,7 +219,15 @@ func (app *App) maxCB() {
 		if err != nil {
 			return
 		}
-		app.conf.MaxLen = int(i)
+		c := *app.conf
+		c.MaxLen = int(i)
+		if err := validateField(&c, "maxLen"); err != nil {
+			app.ui.log.SetValue(err.Error())
+			return
+		}
+
+		app.conf.MaxLen = c.MaxLen
+		app.settingsChanged()
 	})
 }
 
@@ -147,14 +242,28 @@ func (app *App) wcCB() {
 		if err != nil {
 			return
 		}
-		app.conf.WordCount = int(i)
+		c := *app.conf
+		c.WordCount = int(i)
+		if err := validateField(&c, "wordCount"); err != nil {
+			app.ui.log.SetValue(err.Error())
+			return
+		}
+
+		app.conf.WordCount = c.WordCount
+		app.settingsChanged()
 	})
 }
 
 // Called when the app attempts to exit, such as the window closing or ctrl+c
 // interrupt signal on the command line.
 func (app *App) gracefulExit() {
+	if app.incognito {
+		Log("closing app without saving, since incognito mode is on")
+		os.Exit(0)
+	}
+
 	Log("closing app and saving config, please wait a moment...")
+	app.cancelPendingSave()
 	err := app.saveConfig()
 	if err != nil {
 		log.Printf("failed to save config: %v", err.Error())
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pwiecz/go-fltk"
+	"golang.org/x/term"
+)
+
+// Input widget types from FLTK, which aren't exported by go-fltk.
+const (
+	NORMAL_INPUT uint8 = 0
+	SECRET_INPUT uint8 = 5
+)
+
+// Returns the dictionary that passwords are checked against, built from the
+// selected language's simple and extended word lists. The extended list is
+// loaded temporarily if it isn't already in use.
+func (app *App) dictionary() map[string]float64 {
+	if app.dict != nil {
+		return app.dict
+	}
+
+	lists := [][]string{app.words.Simple}
+	if len(app.words.Complex) > 0 {
+		lists = append(lists, app.words.Complex)
+	} else if lang := findLanguage(app.langs, app.lang); lang.hasComplex {
+		complex, err := loadWords(lang.fsys, COMPLEX_WORDS_FILE)
+		if err != nil {
+			Logf("failed to load complex words for the strength checker: %v", err.Error())
+		} else {
+			lists = append(lists, complex)
+		}
+	}
+
+	app.dict = buildDictionary(lists...)
+
+	return app.dict
+}
+
+// Checks the strength of a password, and whether it has been seen in the
+// breach data. Breached passwords are tried first by attackers, so they always
+// get the lowest score.
+func (app *App) checkPassword(password string) (Strength, error) {
+	s := checkStrength(password, app.dictionary(), app.conf.Attackers)
+
+	n, err := app.breached(password)
+	if err != nil {
+		return s, err
+	}
+
+	if n > 0 {
+		s.Breaches = n
+		s.Score = 0
+	}
+
+	return s, nil
+}
+
+// Formats the strength of a password as HTML for display in a HelpView.
+func strengthHTML(s Strength) string {
+	sb := new(strings.Builder)
+	if s.Breaches > 0 {
+		fmt.Fprintf(sb, "<font color=\"red\"><b>Found in breach data %v times - do not use this password</b></font><br>", s.Breaches)
+	}
+
+	fmt.Fprintf(sb, "<b>Score: %v/4 (%v)</b><br>", s.Score, scoreName(s.Score))
+	fmt.Fprintf(sb, "Estimated guesses: 10<sup>%.1f</sup><br><br>", s.GuessesLog10)
+	sb.WriteString("<b>Time to crack</b><br>")
+	for _, c := range s.CrackTimes {
+		fmt.Fprintf(sb, "%v: %v<br>", html.EscapeString(c.Scenario), html.EscapeString(c.Display))
+	}
+
+	sb.WriteString("<br><b>Patterns</b><br>")
+	for _, p := range s.Patterns {
+		fmt.Fprintf(sb, "%v<br>", html.EscapeString(p))
+	}
+
+	return sb.String()
+}
+
+// Shows a window for checking the strength of existing passwords. Everything
+// happens offline, and the password is never logged.
+func (app *App) checkWindow() {
+	win := fltk.NewWindow(400, 320, "Check Password Strength")
+	in := fltk.NewInput(10, 25, 290, 25, "Password:")
+	show := fltk.NewCheckButton(310, 25, 80, 25, "S&how")
+	result := fltk.NewHelpView(10, 60, 380, 250, "")
+	win.End()
+	win.Resizable(result)
+
+	in.SetType(SECRET_INPUT)
+	in.SetAlign(fltk.ALIGN_TOP_LEFT)
+	in.SetTooltip("Paste or type a password to check. It is never saved, logged or sent anywhere.")
+	result.SetValue("Type a password to check its strength.")
+
+	in.SetCallbackCondition(fltk.WhenChanged)
+	in.SetCallback(func() {
+		if in.Value() == "" {
+			result.SetValue("Type a password to check its strength.")
+			return
+		}
+
+		s, err := app.checkPassword(in.Value())
+		if err != nil {
+			result.SetValue(fmt.Sprintf("Failed to check the breach data: %v<br><br>%v", html.EscapeString(err.Error()), strengthHTML(s)))
+			return
+		}
+
+		result.SetValue(strengthHTML(s))
+	})
+
+	show.SetCallback(func() {
+		if show.Value() {
+			in.SetType(NORMAL_INPUT)
+		} else {
+			in.SetType(SECRET_INPUT)
+		}
+
+		in.Redraw()
+	})
+
+	win.SetCallback(func() {
+		in.SetValue("")
+		win.Hide()
+		// free up the memory used by the dictionary
+		app.dict = nil
+	})
+
+	win.Show()
+}
+
+// Runs the check subcommand, which checks the strength of passwords read from
+// standard input, one per line. If standard input is a terminal, the password
+// is prompted for without echoing it. Returns the process exit code.
+func (app *App) check(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	_ = fs.Parse(args)
+
+	passwords := []string{}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read password: %v\n", err.Error())
+			return 1
+		}
+
+		passwords = append(passwords, string(b))
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			passwords = append(passwords, scanner.Text())
+		}
+
+		if err := scanner.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read passwords: %v\n", err.Error())
+			return 1
+		}
+	}
+
+	return app.reportStrength(os.Stdout, passwords, *asJSON)
+}
+
+// Checks the passwords and prints the results to out, either as text or as
+// JSON. Returns the process exit code.
+func (app *App) reportStrength(out io.Writer, passwords []string, asJSON bool) int {
+	results := make([]Strength, len(passwords))
+	for i, p := range passwords {
+		s, err := app.checkPassword(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to check breach data: %v\n", err.Error())
+			return 1
+		}
+
+		results[i] = s
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode results: %v\n", err.Error())
+			return 1
+		}
+
+		return 0
+	}
+
+	for i, s := range results {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+
+		if s.Breaches > 0 {
+			fmt.Fprintf(out, "breached: found %v times\n", s.Breaches)
+		}
+
+		fmt.Fprintf(out, "score: %v/4 (%v)\n", s.Score, scoreName(s.Score))
+		fmt.Fprintf(out, "guesses: 10^%.1f\n", s.GuessesLog10)
+		for _, c := range s.CrackTimes {
+			fmt.Fprintf(out, "%v: %v\n", c.Scenario, c.Display)
+		}
+
+		for _, p := range s.Patterns {
+			fmt.Fprintf(out, "pattern: %v\n", p)
+		}
+	}
+
+	return 0
+}
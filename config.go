@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/adrg/xdg"
+)
+
+// Prefix of the environment variables that override config values, such as
+// GFD_WORD_COUNT for wordCount.
+const ENV_PREFIX = "GFD_"
+
+// Sources of config values, from lowest to highest precedence. System and
+// user configs are followed by their path, environment variables by their
+// name, and flags by their name.
+const (
+	SOURCE_DEFAULT = "default"
+	SOURCE_SYSTEM  = "system config"
+	SOURCE_USER    = "user config"
+	SOURCE_ENV     = "environment variable"
+	SOURCE_FLAG    = "flag"
+)
+
+// Flags that set config values, and the config keys that they set.
+var configFlags = map[string]string{
+	"s":           "separator",
+	"max":         "maxLen",
+	"min":         "minLen",
+	"wc":          "wordCount",
+	"mix":         "mix",
+	"extra":       "mix",
+	"lang":        "lang",
+	"breach":      "breachFile",
+	"min-entropy": "minEntropy",
+	"team-policy": "teamPolicy",
+}
+
+// Config values from the command line. Only flags that were explicitly set are
+// applied over the other config layers.
+var flagConf = &AppConfig{}
+
+// configLayers tracks where each config value came from, so that the effective
+// config can be explained, and so that only the user's own settings are saved.
+type configLayers struct {
+	// the source of each key's effective value
+	sources map[string]string
+	// the values from the defaults and system configs, which the user config
+	// overrides
+	base map[string]json.RawMessage
+	// the values that were read from the user config file
+	user map[string]json.RawMessage
+	// the values from environment variables, flags and policies, which are
+	// never saved
+	overrides map[string]json.RawMessage
+	// the keys that are locked by a policy, and the policy file that locked
+	// them
+	locked map[string]string
+	// if set, the reason that the user config must not be overwritten, so that
+	// none of its settings are lost
+	preserve string
+	// if true, the user config had invalid values, which are dropped when
+	// it's saved
+	invalid bool
+}
+
+// Returns the built-in default config.
+func defaultConfig() AppConfig {
+	return AppConfig{
+		Theme:     THEME_LIGHT,
+		Mix:       MIX_SIMPLE,
+		Lang:      DEFAULT_LANG,
+		MaxLen:    64,
+		MinLen:    20,
+		Separator: " ",
+		WordCount: 3,
+		Attackers: defaultAttackers(),
+		Version:   CONFIG_VERSION,
+	}
+}
+
+// Returns the config keys in the order that they're declared in AppConfig,
+// except for the version.
+func configKeys() []string {
+	t := reflect.TypeOf(AppConfig{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "version" {
+			continue
+		}
+
+		keys = append(keys, name)
+	}
+
+	return keys
+}
+
+// Returns the config key that k refers to, or false if there is none. Keys
+// are matched case-insensitively, the same as encoding/json does when decoding
+// AppConfig, so that "WordCount" is treated as wordCount everywhere.
+func canonicalKey(k string) (string, bool) {
+	for _, key := range configKeys() {
+		if strings.EqualFold(k, key) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// Converts a config key to the environment variable that overrides it, such
+// as wordCount to GFD_WORD_COUNT.
+func envName(key string) string {
+	sb := new(strings.Builder)
+	sb.WriteString(ENV_PREFIX)
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			sb.WriteRune('_')
+		}
+
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+
+	return sb.String()
+}
+
+// Returns the JSON representation of every config value, by key.
+func configValues(conf *AppConfig) map[string]json.RawMessage {
+	b, err := json.Marshal(conf)
+	if err != nil {
+		log.Printf("failed to marshal config: %v", err.Error())
+		return map[string]json.RawMessage{}
+	}
+
+	values := map[string]json.RawMessage{}
+	_ = json.Unmarshal(b, &values)
+
+	return values
+}
+
+// Sets a single config value from its JSON representation.
+func setConfigValue(conf *AppConfig, key string, raw json.RawMessage) error {
+	// decode into a copy, so that a bad value doesn't leave conf half-updated
+	c := *conf
+
+	// clear the field first, since encoding/json decodes into existing slices
+	// in place, which would change conf through the shared array and merge the
+	// new elements with the old ones
+	v := reflect.ValueOf(&c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if strings.EqualFold(name, key) {
+			v.Field(i).SetZero()
+		}
+	}
+
+	b, err := json.Marshal(map[string]json.RawMessage{key: raw})
+	if err != nil {
+		return fmt.Errorf("invalid value for %v: %v", key, err.Error())
+	}
+
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return fmt.Errorf("invalid value for %v: %v", key, err.Error())
+	}
+
+	*conf = c
+
+	return nil
+}
+
+// Parses the value of an environment variable. Values are JSON, except that
+// strings don't need to be quoted.
+func envValue(conf *AppConfig, key string, v string) (json.RawMessage, error) {
+	c := *conf
+	if json.Valid([]byte(v)) && setConfigValue(&c, key, json.RawMessage(v)) == nil {
+		return json.RawMessage(v), nil
+	}
+
+	quoted, _ := json.Marshal(v)
+	if err := setConfigValue(&c, key, quoted); err != nil {
+		return nil, err
+	}
+
+	return quoted, nil
+}
+
+// Sets a config value from one of the layers if it's valid, and records where
+// it came from. If it's invalid, the value from the layer below is kept.
+func (app *App) applyValue(name string, raw json.RawMessage, source string) error {
+	key, ok := canonicalKey(name)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+
+	c := *app.conf
+	err := setConfigValue(&c, key, raw)
+	if err != nil {
+		return err
+	}
+
+	err = validateField(&c, key)
+	if err != nil {
+		return err
+	}
+
+	*app.conf = c
+	app.layers.sources[key] = source
+
+	return nil
+}
+
+// Applies a config file over the config, and records the source of each value
+// that it sets. Returns the values that it set; invalid values are skipped with
+// a warning.
+func (app *App) applyConfigFile(p string, source string) (map[string]json.RawMessage, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := decodeConfig(p, b)
+	if err != nil {
+		return nil, fmt.Errorf("config file %v failed to parse: %v", p, err.Error())
+	}
+
+	from, err := migrateConfig(values)
+	if errors.Is(err, errNewerConfig) {
+		log.Printf("config file %v: %v; loading what's understood", p, err.Error())
+		if source == SOURCE_USER {
+			app.layers.preserve = "it was written by a newer version of this app"
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("config file %v: %v", p, err.Error())
+	} else if from < CONFIG_VERSION {
+		log.Printf("migrated config file %v from version %v to %v", p, from, CONFIG_VERSION)
+		// system configs are read-only and are left for the admin to upgrade
+		if source == SOURCE_USER && !app.incognito {
+			err := backupConfig(p, b, from)
+			if err != nil {
+				log.Println(err.Error())
+			}
+		}
+	}
+
+	// keys are matched case-insensitively, so they're kept by their canonical
+	// name from here on
+	decoded := values
+	values = map[string]json.RawMessage{"version": decoded["version"]}
+	for _, name := range sortedKeys(decoded) {
+		if name == "version" {
+			continue
+		}
+
+		raw := decoded[name]
+		k, ok := canonicalKey(name)
+		if !ok {
+			app.warn("ignoring unknown setting %q in %v", name, p)
+			if source == SOURCE_USER {
+				app.layers.invalid = true
+			}
+
+			continue
+		}
+
+		values[k] = raw
+		err := app.applyValue(k, raw, fmt.Sprintf("%v %v", source, p))
+		if err != nil {
+			app.warn("ignoring invalid value in %v: %v; using %v", p, err.Error(), app.layers.sources[k])
+			delete(values, k)
+			if source == SOURCE_USER {
+				app.layers.invalid = true
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// Applies the system configs from the XDG config dirs, such as
+// /etc/xdg/go-fltk-diceware/config.json (or .yaml or .toml). Dirs earlier in
+// XDG_CONFIG_DIRS take precedence. The highest minimum entropy of any system
+// config is kept, since users can't lower it.
+func (app *App) applySystemConfigs() {
+	for i := len(xdg.ConfigDirs) - 1; i >= 0; i-- {
+		p := findConfigFile(path.Join(xdg.ConfigDirs[i], APP_NAME), CONFIG_FILE)
+		if p == "" {
+			continue
+		}
+
+		values, err := app.applyConfigFile(p, SOURCE_SYSTEM)
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		log.Printf("loaded system config from %v", p)
+
+		if _, ok := values["minEntropy"]; ok && app.conf.MinEntropy > app.systemMinEntropy {
+			app.systemMinEntropy = app.conf.MinEntropy
+			app.systemConfigPath = p
+		}
+	}
+}
+
+// Applies GFD_* environment variables over the config.
+func (app *App) applyEnv() {
+	for _, k := range configKeys() {
+		name := envName(k)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		raw, err := envValue(app.conf, k, v)
+		if err == nil {
+			err = app.applyValue(k, raw, fmt.Sprintf("%v %v", SOURCE_ENV, name))
+		}
+
+		if err != nil {
+			app.warn("ignoring %v: %v; using %v", name, err.Error(), app.layers.sources[k])
+			continue
+		}
+
+		app.layers.overrides[k] = configValues(app.conf)[k]
+	}
+}
+
+// Applies the flags that were explicitly set on the command line over the
+// config.
+func (app *App) applyFlags() {
+	values := configValues(flagConf)
+	flag.Visit(func(f *flag.Flag) {
+		k, ok := configFlags[f.Name]
+		if !ok {
+			return
+		}
+
+		err := app.applyValue(k, values[k], fmt.Sprintf("%v -%v", SOURCE_FLAG, f.Name))
+		if err != nil {
+			app.warn("ignoring -%v: %v; using %v", f.Name, err.Error(), app.layers.sources[k])
+			return
+		}
+
+		app.layers.overrides[k] = configValues(app.conf)[k]
+	})
+}
+
+// Returns the keys of the map in sorted order, so that values are applied and
+// warned about in a predictable order.
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Returns the config values that should be saved to the user config: values
+// that were in the user config, and values that the user changed in the app.
+// Values that only came from the defaults, the system configs, environment
+// variables or flags are left out, so that they keep applying from there.
+func (app *App) savedValues() map[string]json.RawMessage {
+	saved := map[string]json.RawMessage{}
+	for k, v := range configValues(app.conf) {
+		if o, ok := app.layers.overrides[k]; ok && bytes.Equal(v, o) {
+			if u, ok := app.layers.user[k]; ok {
+				saved[k] = u
+			}
+
+			continue
+		}
+
+		_, inUser := app.layers.user[k]
+		if inUser || !bytes.Equal(v, app.layers.base[k]) {
+			saved[k] = v
+		}
+	}
+
+	saved["version"] = json.RawMessage(strconv.Itoa(CONFIG_VERSION))
+
+	return saved
+}
+
+// Prints every effective config value along with where it came from.
+func (app *App) printConfig(out io.Writer) {
+	values := configValues(app.conf)
+	for _, k := range configKeys() {
+		fmt.Fprintf(out, "%v = %s (%v)\n", k, values[k], app.layers.sources[k])
+	}
+}
+
+// Runs the config subcommand, which prints the effective config. Returns the
+// process exit code.
+func (app *App) configCommand(args []string) int {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	_ = fs.Parse(args)
+
+	fmt.Fprintf(os.Stdout, "# user config file: %v\n", app.configFilePath)
+	app.printConfig(os.Stdout)
+
+	return 0
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adrg/xdg"
+)
+
+// Returns an app with the default config and empty config layers, without any
+// of the config files on this machine.
+func testApp() *App {
+	a := &App{
+		conf: &AppConfig{},
+		layers: configLayers{
+			sources:   map[string]string{},
+			user:      map[string]json.RawMessage{},
+			overrides: map[string]json.RawMessage{},
+			locked:    map[string]string{},
+		},
+	}
+
+	*a.conf = defaultConfig()
+	for _, k := range configKeys() {
+		a.layers.sources[k] = SOURCE_DEFAULT
+	}
+
+	a.layers.base = configValues(a.conf)
+
+	return a
+}
+
+// Points the XDG config dirs at empty temporary dirs, so that loading the
+// config doesn't pick up the system or policy configs on this machine.
+func isolateConfigDirs(t *testing.T) {
+	t.Helper()
+
+	dirs, home := xdg.ConfigDirs, xdg.ConfigHome
+	t.Cleanup(func() { xdg.ConfigDirs, xdg.ConfigHome = dirs, home })
+	xdg.ConfigDirs = []string{t.TempDir()}
+	xdg.ConfigHome = t.TempDir()
+}
+
+// A min length above the max length falls back to the default lengths. If
+// either came from the user config, a copy of it is kept before the next save
+// drops them.
+func TestInvalidLengths(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		env       map[string]string
+		incognito bool
+		keepCopy  bool
+	}{
+		{"both from the user config", `{"version": 2, "minLen": 50, "maxLen": 20}`, nil, false, true},
+		{"min length from the user config", `{"version": 2, "minLen": 50}`, map[string]string{"maxLen": "20"}, false, true},
+		{"max length from the user config", `{"version": 2, "maxLen": 20}`, map[string]string{"minLen": "50"}, false, true},
+		{"neither from the user config", `{"version": 2, "wordCount": 5}`, map[string]string{"minLen": "50", "maxLen": "20"}, false, false},
+		{"incognito", `{"version": 2, "minLen": 50, "maxLen": 20}`, nil, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateConfigDirs(t)
+			for k, v := range tt.env {
+				t.Setenv(envName(k), v)
+			}
+
+			app := testApp()
+			app.incognito = tt.incognito
+			app.configFilePath = filepath.Join(t.TempDir(), CONFIG_FILE)
+			writeTestFile(t, app.configFilePath, tt.config)
+			app.loadConfig()
+
+			d := defaultConfig()
+			if app.conf.MinLen != d.MinLen || app.conf.MaxLen != d.MaxLen {
+				t.Errorf("got lengths %v-%v, want the defaults %v-%v", app.conf.MinLen, app.conf.MaxLen, d.MinLen, d.MaxLen)
+			}
+
+			b, err := os.ReadFile(app.configFilePath + INVALID_EXT)
+			if !tt.keepCopy {
+				if err == nil {
+					t.Errorf("kept a copy of the config")
+				}
+
+				return
+			}
+
+			if err != nil || string(b) != tt.config {
+				t.Fatalf("got copy %q (%v), want %q", b, err, tt.config)
+			}
+
+			// saving drops the invalid values, but the copy still has them
+			if err := app.saveConfig(); err != nil {
+				t.Fatal(err)
+			}
+
+			b, _ = os.ReadFile(app.configFilePath)
+			saved, err := decodeConfig(app.configFilePath, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(saved["minLen"]) == "50" || string(saved["maxLen"]) == "20" {
+				t.Errorf("saved config still has the invalid values: %s", b)
+			}
+
+			if b, _ := os.ReadFile(app.configFilePath + INVALID_EXT); string(b) != tt.config {
+				t.Errorf("the copy changed to %q", b)
+			}
+		})
+	}
+}
+
+// Runs config files in every format through the same steps as
+// applyConfigFile. Whatever the file contains, loading it must not panic, and
+// every value that is applied must leave the config valid.
+func FuzzLoadConfig(f *testing.F) {
+	seeds := []struct {
+		ext  string
+		data string
+	}{
+		{".json", `{"version": 2, "wordCount": 4, "mix": "2:1", "separator": "-"}`},
+		{".json", `{"useExtendedWordList": true, "darkMode": true}`},
+		{".json", `{"version": -1}`},
+		{".json", `{"version": 99, "wordCount": 0, "maxLen": -1}`},
+		{".json", `{"attackers": [{"name": "gpu", "guessesPerSecond": 1e10}], "minEntropy": 60}`},
+		{".json", `{"themes": [{"name": "mine", "bg": "#000000"}], "theme": "mine"}`},
+		{".json", `null`},
+		{".json", `{"WordCount": 5, "wordCount": 0, "unknown": true}`},
+		{".json", `{"version": 2, "minLen": 50, "maxLen": 20}`},
+		{".yaml", "version: 2\nwordCount: 5\nseparator: \"_\"\n"},
+		{".yaml", "version: 1\ndarkMode: true\nattackers:\n  - name: x\n    guessesPerSecond: 1\n"},
+		{".yaml", "- 1\n- 2\n"},
+		{".toml", "version = 2\nwordCount = 3\nmix = \"union\"\n"},
+		{".toml", "version = 0\nuseExtendedWordList = true\n[[attackers]]\nname = \"x\"\nguessesPerSecond = 5.0\n"},
+	}
+
+	for _, s := range seeds {
+		f.Add(s.ext, []byte(s.data))
+	}
+
+	f.Fuzz(func(t *testing.T, ext string, data []byte) {
+		p := "config" + ext
+		values, err := decodeConfig(p, data)
+		if err != nil {
+			return
+		}
+
+		_, err = migrateConfig(values)
+		if err != nil && !errors.Is(err, errNewerConfig) {
+			return
+		}
+
+		a := testApp()
+		for _, k := range sortedKeys(values) {
+			if k == "version" {
+				continue
+			}
+
+			_ = a.applyValue(k, values[k], SOURCE_USER)
+		}
+
+		for _, k := range configKeys() {
+			if err := validateField(a.conf, k); err != nil {
+				t.Fatalf("loading %q left an invalid config: %v", data, err.Error())
+			}
+		}
+
+		conflict := a.conf.MinLen > a.conf.MaxLen
+		a.validateConfig()
+		if a.conf.MinLen > a.conf.MaxLen {
+			t.Fatalf("loading %q left minLen %v above maxLen %v", data, a.conf.MinLen, a.conf.MaxLen)
+		}
+
+		// conflicting lengths from the user config are dropped on the next
+		// save, so a copy of it must be kept
+		if conflict && !a.layers.invalid {
+			t.Fatalf("loading %q with minLen above maxLen didn't mark the config as invalid", data)
+		}
+
+		// the result must be saveable
+		if _, err := encodeConfig(p, a.savedValues(), data); err != nil {
+			t.Fatalf("failed to encode the config loaded from %q: %v", data, err.Error())
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+)
+
+// An attacker model: a kind of attack, and how many guesses per second it can
+// make. Configurable in the config file.
+type Attacker struct {
+	Name             string  `json:"name"`
+	GuessesPerSecond float64 `json:"guessesPerSecond"`
+}
+
+// The attacker models that are used when none are configured.
+func defaultAttackers() []Attacker {
+	return []Attacker{
+		{Name: "online attack, throttled (100/hour)", GuessesPerSecond: 100.0 / 3600},
+		{Name: "offline attack, bcrypt (10k/second)", GuessesPerSecond: 1e4},
+		{Name: "offline attack, fast hash (10B/second)", GuessesPerSecond: 1e10},
+		{Name: "nation-state GPU cluster (1 quadrillion/second)", GuessesPerSecond: 1e15},
+	}
+}
+
+// Estimates the time to crack a password with the given number of guesses,
+// for every attacker. Attackers without a positive hash rate are skipped.
+func crackTimes(guesses float64, attackers []Attacker) []CrackTime {
+	result := make([]CrackTime, 0, len(attackers))
+	for _, a := range attackers {
+		if a.GuessesPerSecond <= 0 {
+			continue
+		}
+
+		seconds := guesses / a.GuessesPerSecond
+		result = append(result, CrackTime{Scenario: a.Name, Seconds: seconds, Display: formatDuration(seconds)})
+	}
+
+	return result
+}
+
+// Estimates the average time to crack a generated password with the given
+// entropy, for every attacker. On average, an attacker finds the password
+// after searching half of the possibilities.
+func entropyCrackTimes(bits float64, attackers []Attacker) []CrackTime {
+	if bits <= 0 {
+		return crackTimes(1, attackers)
+	}
+
+	return crackTimes(math.Pow(2, bits-1), attackers)
+}
+
+// Estimates the average time to crack a password generated with the current
+// settings.
+func (app *App) crackTimes() []CrackTime {
+	return entropyCrackTimes(app.entropy(), app.conf.Attackers)
+}
+
+// Formats crack times as HTML for the UI log.
+func crackTimesHTML(times []CrackTime) string {
+	lines := make([]string, len(times))
+	for i, c := range times {
+		lines[i] = fmt.Sprintf("%v: %v", html.EscapeString(c.Scenario), html.EscapeString(c.Display))
+	}
+
+	return strings.Join(lines, "<br>")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEntropyCrackTimes(t *testing.T) {
+	attackers := []Attacker{
+		{Name: "slow", GuessesPerSecond: 1},
+		{Name: "fast", GuessesPerSecond: 1e10},
+		{Name: "stopped", GuessesPerSecond: 0},
+		{Name: "broken", GuessesPerSecond: -5},
+	}
+
+	tests := []struct {
+		name string
+		bits float64
+		// the expected seconds for the slow and fast attackers
+		seconds []float64
+	}{
+		{"40 bits", 40, []float64{math.Pow(2, 39), math.Pow(2, 39) / 1e10}},
+		{"one bit", 1, []float64{1, 1e-10}},
+		{"fractional bits", 10.5, []float64{math.Pow(2, 9.5), math.Pow(2, 9.5) / 1e10}},
+		// a password with no entropy is found with the first guess
+		{"zero entropy", 0, []float64{1, 1e-10}},
+		{"negative entropy", -3, []float64{1, 1e-10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			times := entropyCrackTimes(tt.bits, attackers)
+
+			// attackers without a positive rate are skipped
+			if len(times) != len(tt.seconds) {
+				t.Fatalf("got %v crack times, want %v", len(times), len(tt.seconds))
+			}
+
+			for i, c := range times {
+				if c.Scenario != attackers[i].Name {
+					t.Errorf("got scenario %q, want %q", c.Scenario, attackers[i].Name)
+				}
+
+				if math.Abs(c.Seconds-tt.seconds[i]) > tt.seconds[i]*1e-12 {
+					t.Errorf("%v: got %v seconds, want %v", c.Scenario, c.Seconds, tt.seconds[i])
+				}
+
+				if c.Display != formatDuration(c.Seconds) {
+					t.Errorf("%v: got display %q, want %q", c.Scenario, c.Display, formatDuration(c.Seconds))
+				}
+			}
+		})
+	}
+}
+
+func TestCrackTimes(t *testing.T) {
+	times := crackTimes(3600, defaultAttackers())
+	// 36 hours online, and well under a second offline
+	want := []string{"2 days", "less than a second", "less than a second", "less than a second"}
+	if len(times) != len(want) {
+		t.Fatalf("got %v crack times, want %v", len(times), len(want))
+	}
+
+	for i, c := range times {
+		if c.Display != want[i] {
+			t.Errorf("%v: got %q, want %q", c.Scenario, c.Display, want[i])
+		}
+	}
+
+	if times := crackTimes(1e6, nil); len(times) != 0 {
+		t.Errorf("got %v crack times without any attackers", len(times))
+	}
+
+	if times := crackTimes(1e6, []Attacker{{Name: "none", GuessesPerSecond: 0}}); len(times) != 0 {
+		t.Errorf("got %v crack times for an attacker with no guesses per second", len(times))
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pwiecz/go-fltk"
+)
+
+// The theme that follows the desktop's light/dark preference, using the light
+// or dark theme.
+const THEME_AUTO = "auto"
+
+// The freedesktop settings portal, which publishes the desktop's color scheme
+// preference on the session bus.
+const (
+	PORTAL_DEST          = "org.freedesktop.portal.Desktop"
+	PORTAL_PATH          = "/org/freedesktop/portal/desktop"
+	PORTAL_SETTINGS      = "org.freedesktop.portal.Settings"
+	APPEARANCE_NAMESPACE = "org.freedesktop.appearance"
+	COLOR_SCHEME_KEY     = "color-scheme"
+)
+
+// Values of the color-scheme setting.
+const (
+	COLOR_SCHEME_NONE  uint32 = 0
+	COLOR_SCHEME_DARK  uint32 = 1
+	COLOR_SCHEME_LIGHT uint32 = 2
+)
+
+// How long to wait for the settings portal before falling back to environment
+// hints, so that a missing portal can't hold up startup.
+const portalTimeout = 2 * time.Second
+
+// Returns the value of a color-scheme setting. Older portals wrap the value in
+// more than one variant.
+func colorSchemeValue(v dbus.Variant) (uint32, error) {
+	value := v.Value()
+	for {
+		inner, ok := value.(dbus.Variant)
+		if !ok {
+			break
+		}
+
+		value = inner.Value()
+	}
+
+	scheme, ok := value.(uint32)
+	if !ok {
+		return 0, fmt.Errorf("unexpected color-scheme value %v", v)
+	}
+
+	return scheme, nil
+}
+
+// Reads the color-scheme preference from the settings portal.
+func readColorScheme(conn *dbus.Conn) (uint32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), portalTimeout)
+	defer cancel()
+
+	obj := conn.Object(PORTAL_DEST, PORTAL_PATH)
+
+	var v dbus.Variant
+	err := obj.CallWithContext(ctx, PORTAL_SETTINGS+".ReadOne", 0, APPEARANCE_NAMESPACE, COLOR_SCHEME_KEY).Store(&v)
+	if err != nil {
+		// ReadOne is only available since version 2 of the portal
+		err = obj.CallWithContext(ctx, PORTAL_SETTINGS+".Read", 0, APPEARANCE_NAMESPACE, COLOR_SCHEME_KEY).Store(&v)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %v from the settings portal: %v", COLOR_SCHEME_KEY, err.Error())
+	}
+
+	return colorSchemeValue(v)
+}
+
+// Returns true if environment variables hint at a dark desktop: a GTK_THEME
+// such as "Adwaita:dark" or "Adwaita-dark", or a COLORFGBG whose background is
+// dark, as set by some terminals.
+func envPrefersDark() bool {
+	if strings.Contains(strings.ToLower(os.Getenv("GTK_THEME")), "dark") {
+		return true
+	}
+
+	if v := os.Getenv("COLORFGBG"); v != "" {
+		fields := strings.Split(v, ";")
+		bg, err := strconv.Atoi(fields[len(fields)-1])
+		if err == nil && (bg < 7 || bg == 8) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if the color-scheme setting prefers a dark theme, falling back
+// to environment hints if it has no preference.
+func schemeDark(scheme uint32) bool {
+	if scheme == COLOR_SCHEME_NONE {
+		return envPrefersDark()
+	}
+
+	return scheme == COLOR_SCHEME_DARK
+}
+
+// Sets whether the desktop prefers a dark theme, and applies it if the auto
+// theme is selected. Must be called on the UI thread.
+func (app *App) setDesktopDark(dark bool) {
+	if dark == app.desktopDark {
+		return
+	}
+
+	app.desktopDark = dark
+	if app.conf.Theme == THEME_AUTO {
+		app.applyTheme()
+	}
+}
+
+// Reads the color-scheme preference from the settings portal on the bus, and
+// calls changed with the new preference whenever it changes, until the
+// connection is closed. Returns the current preference.
+func followColorScheme(conn *dbus.Conn, changed func(scheme uint32)) (uint32, error) {
+	scheme, err := readColorScheme(conn)
+	if err != nil {
+		return COLOR_SCHEME_NONE, err
+	}
+
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(PORTAL_PATH),
+		dbus.WithMatchInterface(PORTAL_SETTINGS),
+		dbus.WithMatchMember("SettingChanged"),
+	)
+	if err != nil {
+		return scheme, fmt.Errorf("failed to watch the settings portal for changes: %v", err.Error())
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	go func() {
+		for sig := range signals {
+			if sig.Name != PORTAL_SETTINGS+".SettingChanged" || len(sig.Body) != 3 {
+				continue
+			}
+
+			namespace, _ := sig.Body[0].(string)
+			key, _ := sig.Body[1].(string)
+			value, ok := sig.Body[2].(dbus.Variant)
+			if namespace != APPEARANCE_NAMESPACE || key != COLOR_SCHEME_KEY || !ok {
+				continue
+			}
+
+			scheme, err := colorSchemeValue(value)
+			if err != nil {
+				log.Println(err.Error())
+				continue
+			}
+
+			changed(scheme)
+		}
+	}()
+
+	return scheme, nil
+}
+
+// Reads the desktop's light/dark preference from the settings portal, and
+// follows any changes to it for the rest of the session. Uses environment
+// hints until the portal answers, and if it isn't available or has no
+// preference. Only connects to the bus once, the first time that the auto theme
+// is used, so that other themes never wait on the portal. The portal is read in
+// the background, so that a slow one can't hold up the UI.
+func (app *App) watchDesktopTheme() {
+	if app.desktopWatched {
+		return
+	}
+
+	app.desktopWatched = true
+	app.desktopDark = envPrefersDark()
+
+	go func() {
+		conn, err := dbus.ConnectSessionBus()
+		if err != nil {
+			log.Printf("failed to connect to the session bus, using environment hints for the auto theme: %v", err.Error())
+			return
+		}
+
+		scheme, err := followColorScheme(conn, func(scheme uint32) {
+			log.Printf("desktop color scheme changed to %v", scheme)
+			dark := schemeDark(scheme)
+			fltk.Awake(func() { app.setDesktopDark(dark) })
+		})
+		if err != nil {
+			log.Printf("%v; the auto theme won't follow changes to the desktop's preference", err.Error())
+			conn.Close()
+		}
+
+		dark := schemeDark(scheme)
+		fltk.Awake(func() { app.setDesktopDark(dark) })
+	}()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// A stand-in for the settings portal, which only has the Read method of
+// version 1 of the portal, and wraps values in an extra variant like older
+// portals do.
+type fakePortalV1 struct {
+	scheme uint32
+}
+
+func (p *fakePortalV1) Read(namespace, key string) (dbus.Variant, *dbus.Error) {
+	if namespace != APPEARANCE_NAMESPACE || key != COLOR_SCHEME_KEY {
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.portal.Error.NotFound", nil)
+	}
+
+	return dbus.MakeVariant(dbus.MakeVariant(p.scheme)), nil
+}
+
+// A stand-in for version 2 of the settings portal, which adds ReadOne.
+type fakePortal struct {
+	fakePortalV1
+}
+
+func (p *fakePortal) ReadOne(namespace, key string) (dbus.Variant, *dbus.Error) {
+	if namespace != APPEARANCE_NAMESPACE || key != COLOR_SCHEME_KEY {
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.portal.Error.NotFound", nil)
+	}
+
+	return dbus.MakeVariant(p.scheme), nil
+}
+
+// Starts a private bus with dbus-daemon, and returns its address. Skips the
+// test if dbus-daemon isn't installed.
+func testBus(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon isn't installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read the bus address: %v", err.Error())
+	}
+
+	return strings.TrimSpace(addr)
+}
+
+// Connects to the bus, and closes the connection when the test is done.
+func testConn(t *testing.T, addr string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatalf("failed to connect to the bus: %v", err.Error())
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// Publishes the stand-in portal on the bus, under the real portal's name.
+func servePortal(t *testing.T, addr string, portal any) *dbus.Conn {
+	t.Helper()
+
+	conn := testConn(t, addr)
+	if err := conn.Export(portal, PORTAL_PATH, PORTAL_SETTINGS); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := conn.RequestName(PORTAL_DEST, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed to own %v: %v", PORTAL_DEST, err)
+	}
+
+	return conn
+}
+
+func TestReadColorScheme(t *testing.T) {
+	addr := testBus(t)
+
+	tests := []struct {
+		name   string
+		portal any
+		want   uint32
+	}{
+		{"ReadOne", &fakePortal{fakePortalV1{scheme: COLOR_SCHEME_DARK}}, COLOR_SCHEME_DARK},
+		{"Read with nested variants", &fakePortalV1{scheme: COLOR_SCHEME_LIGHT}, COLOR_SCHEME_LIGHT},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			portal := servePortal(t, addr, tt.portal)
+			defer func() { _, _ = portal.ReleaseName(PORTAL_DEST) }()
+
+			scheme, err := readColorScheme(testConn(t, addr))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if scheme != tt.want {
+				t.Errorf("got color scheme %v, want %v", scheme, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadColorSchemeWithoutPortal(t *testing.T) {
+	if _, err := readColorScheme(testConn(t, testBus(t))); err == nil {
+		t.Error("expected an error without a settings portal")
+	}
+}
+
+func TestFollowColorScheme(t *testing.T) {
+	addr := testBus(t)
+	portal := servePortal(t, addr, &fakePortal{fakePortalV1{scheme: COLOR_SCHEME_LIGHT}})
+
+	changes := make(chan uint32, 10)
+	scheme, err := followColorScheme(testConn(t, addr), func(scheme uint32) { changes <- scheme })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scheme != COLOR_SCHEME_LIGHT {
+		t.Errorf("got initial color scheme %v, want %v", scheme, COLOR_SCHEME_LIGHT)
+	}
+
+	emit := func(namespace, key string, value any) {
+		err := portal.Emit(PORTAL_PATH, PORTAL_SETTINGS+".SettingChanged", namespace, key, dbus.MakeVariant(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// other settings are ignored
+	emit("org.gnome.desktop.interface", "gtk-theme", "Adwaita")
+	emit(APPEARANCE_NAMESPACE, "accent-color", "blue")
+	emit(APPEARANCE_NAMESPACE, COLOR_SCHEME_KEY, COLOR_SCHEME_DARK)
+	emit(APPEARANCE_NAMESPACE, COLOR_SCHEME_KEY, dbus.MakeVariant(COLOR_SCHEME_NONE))
+
+	for _, want := range []uint32{COLOR_SCHEME_DARK, COLOR_SCHEME_NONE} {
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Errorf("got color scheme change to %v, want %v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the color scheme to change to %v", want)
+		}
+	}
+}
+
+func TestSchemeDark(t *testing.T) {
+	t.Setenv("GTK_THEME", "Adwaita:dark")
+	t.Setenv("COLORFGBG", "")
+
+	if !schemeDark(COLOR_SCHEME_DARK) || schemeDark(COLOR_SCHEME_LIGHT) {
+		t.Error("an explicit preference must override the environment")
+	}
+
+	if !schemeDark(COLOR_SCHEME_NONE) {
+		t.Error("no preference must fall back to GTK_THEME")
+	}
+
+	t.Setenv("GTK_THEME", "Adwaita")
+	if schemeDark(COLOR_SCHEME_NONE) {
+		t.Error("no preference must fall back to GTK_THEME")
+	}
+}
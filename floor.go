@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Upper bound on the word count that is suggested when the entropy of the
+// current settings is too low.
+const maxSuggestedWordCount = 20
+
+// Returns the minimum entropy, in bits, of generated passwords, and where it
+// was set. The higher of the user's and the system-wide setting applies, so
+// users can raise the floor but not lower it.
+func (app *App) entropyFloor() (float64, string) {
+	if app.systemMinEntropy > app.conf.MinEntropy {
+		return app.systemMinEntropy, fmt.Sprintf("the system config %v", app.systemConfigPath)
+	}
+
+	return app.conf.MinEntropy, "minEntropy in your config"
+}
+
+// Returns an error that explains which settings to change if the current
+// settings don't meet the minimum entropy.
+func (app *App) checkEntropyFloor() error {
+	floor, source := app.entropyFloor()
+	if floor <= 0 {
+		return nil
+	}
+
+	bits := app.entropy()
+	if bits >= floor {
+		return nil
+	}
+
+	m := app.mix
+	c := app.conf
+	suggestions := []string{}
+
+	// a wider length range may be enough on its own
+	wide := c.WordCount*(maxWordLength+len(c.Separator)) + suffixLength
+	if c.MinLen > 0 && entropy(&app.words, m, c.WordCount, c.Separator, max(c.MaxLen, wide), 0) >= floor {
+		suggestions = append(suggestions, "lower the min length or raise the max length")
+	}
+
+	for wc := c.WordCount + 1; wc <= maxSuggestedWordCount; wc++ {
+		if entropy(&app.words, m, wc, c.Separator, c.MaxLen, c.MinLen) >= floor {
+			suggestions = append(suggestions, fmt.Sprintf("increase the word count to %v", wc))
+			break
+		}
+
+		wide = wc*(maxWordLength+len(c.Separator)) + suffixLength
+		if entropy(&app.words, m, wc, c.Separator, wide, c.MinLen) >= floor {
+			suggestions = append(suggestions, fmt.Sprintf("increase the word count to %v and the max length to %v", wc, wide))
+			break
+		}
+	}
+
+	if !m.needsComplex() && findLanguage(app.langs, app.lang).hasComplex {
+		suggestions = append(suggestions, "use the extended word list")
+	}
+
+	if len(suggestions) == 0 {
+		suggestions = append(suggestions, fmt.Sprintf("no word count up to %v, length or mix reaches it with these word lists", maxSuggestedWordCount))
+	}
+
+	return fmt.Errorf("the current settings only give %.1f bits of entropy, below the minimum of %v bits set by %v; %v",
+		bits, floor, source, strings.Join(suggestions, ", or "))
+}
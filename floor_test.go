@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+)
+
+// The higher of the system and the user minimum entropy applies.
+func TestEntropyFloor(t *testing.T) {
+	tests := []struct {
+		name       string
+		system     float64
+		user       float64
+		want       float64
+		wantSource string
+	}{
+		{"none", 0, 0, 0, "minEntropy in your config"},
+		{"user only", 0, 50, 50, "minEntropy in your config"},
+		{"system only", 60, 0, 60, "the system config /etc/xdg/go-fltk-diceware/config.json"},
+		{"user raises the system minimum", 60, 80, 80, "minEntropy in your config"},
+		{"user can't lower the system minimum", 60, 30, 60, "the system config /etc/xdg/go-fltk-diceware/config.json"},
+		{"equal", 60, 60, 60, "minEntropy in your config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testApp()
+			app.systemMinEntropy = tt.system
+			app.systemConfigPath = "/etc/xdg/go-fltk-diceware/config.json"
+			app.conf.MinEntropy = tt.user
+
+			floor, source := app.entropyFloor()
+			if floor != tt.want || source != tt.wantSource {
+				t.Errorf("got %v from %q, want %v from %q", floor, source, tt.want, tt.wantSource)
+			}
+		})
+	}
+}
+
+// A user config that sets a lower minimum than the system config doesn't
+// lower the floor.
+func TestSystemEntropyFloor(t *testing.T) {
+	dir := t.TempDir()
+	saved := xdg.ConfigDirs
+	t.Cleanup(func() { xdg.ConfigDirs = saved })
+	xdg.ConfigDirs = []string{dir}
+
+	system := filepath.Join(dir, APP_NAME, "config.json")
+	writeTestFile(t, system, `{"version": 2, "minEntropy": 60}`)
+	user := filepath.Join(t.TempDir(), "config.json")
+	writeTestFile(t, user, `{"version": 2, "minEntropy": 30}`)
+
+	app := testApp()
+	app.applySystemConfigs()
+	if _, err := app.applyConfigFile(user, SOURCE_USER); err != nil {
+		t.Fatal(err)
+	}
+
+	if app.conf.MinEntropy != 30 {
+		t.Errorf("got minEntropy %v, want the user's 30", app.conf.MinEntropy)
+	}
+
+	floor, source := app.entropyFloor()
+	if floor != 60 || !strings.Contains(source, system) {
+		t.Errorf("got %v from %q, want 60 from %v", floor, source, system)
+	}
+}
+
+// Weak settings are refused with suggestions that name the settings that would
+// fix them.
+func TestCheckEntropyFloor(t *testing.T) {
+	// with the test words, every word adds 4 bits, and 4 simple words with
+	// "-" give 22.6 bits and are at most 29 characters long
+	tests := []struct {
+		name    string
+		mix     Mix
+		complex bool
+		wc      int
+		minLen  int
+		maxLen  int
+		floor   float64
+		want    []string
+	}{
+		{"strong enough", Mix{Simple: 1}, true, 4, 0, 64, 20, nil},
+		{"no floor", Mix{Simple: 1}, true, 1, 0, 64, 0, nil},
+		{
+			"word count", Mix{Simple: 1}, false, 4, 0, 64, 25,
+			[]string{"increase the word count to 5"},
+		},
+		{
+			"word count and max length", Mix{Simple: 1}, false, 4, 0, 30, 25,
+			[]string{fmt.Sprintf("increase the word count to 5 and the max length to %v", 5*(maxWordLength+1)+suffixLength)},
+		},
+		{
+			"min length", Mix{Simple: 1}, false, 4, 30, 64, 20,
+			[]string{"lower the min length or raise the max length", "increase the word count to 5"},
+		},
+		{
+			"mix", Mix{Simple: 1}, true, 4, 0, 64, 25,
+			[]string{"increase the word count to 5", "use the extended word list"},
+		},
+		{
+			"mix only", Mix{Simple: 1}, true, 4, 0, 64, 200,
+			[]string{"use the extended word list"},
+		},
+		{
+			"nothing helps", Mix{Extended: 1}, true, 4, 0, 64, 200,
+			[]string{fmt.Sprintf("no word count up to %v, length or mix reaches it with these word lists", maxSuggestedWordCount)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testApp()
+			app.words = testWords
+			app.mix = tt.mix
+			app.lang = DEFAULT_LANG
+			app.langs = []Language{{Name: DEFAULT_LANG, hasComplex: tt.complex}}
+			app.conf.WordCount = tt.wc
+			app.conf.Separator = "-"
+			app.conf.MinLen = tt.minLen
+			app.conf.MaxLen = tt.maxLen
+			app.conf.MinEntropy = tt.floor
+
+			err := app.checkEntropyFloor()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("got %v, want no error", err)
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("got no error, want suggestions %q", tt.want)
+			}
+
+			_, got, _ := strings.Cut(err.Error(), "set by minEntropy in your config; ")
+			if want := strings.Join(tt.want, ", or "); got != want {
+				t.Errorf("got suggestions %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config file formats, chosen by the file extension. Anything other than YAML
+// or TOML is JSON.
+const (
+	FORMAT_JSON = "json"
+	FORMAT_YAML = "yaml"
+	FORMAT_TOML = "toml"
+)
+
+// Extensions that config files are looked for with, in order of preference,
+// when no config file is given with -f.
+var configExts = []string{".json", ".yaml", ".yml", ".toml"}
+
+// Returns the format of a config file, based on its extension.
+func configFormat(p string) string {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".yaml", ".yml":
+		return FORMAT_YAML
+	case ".toml":
+		return FORMAT_TOML
+	}
+
+	return FORMAT_JSON
+}
+
+// Returns the path of the first config file in dir with any of the supported
+// extensions, such as config.yaml, or "" if there is none.
+func findConfigFile(dir string, name string) string {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	for _, ext := range configExts {
+		p := filepath.Join(dir, base+ext)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+
+	return ""
+}
+
+// Converts a JSON value to plain Go values for the YAML and TOML encoders.
+// Whole numbers become int64, so that they aren't written as floats.
+func jsonToAny(raw json.RawMessage) any {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil
+	}
+
+	var convert func(v any) any
+	convert = func(v any) any {
+		switch t := v.(type) {
+		case json.Number:
+			if i, err := t.Int64(); err == nil {
+				return i
+			}
+
+			f, _ := t.Float64()
+
+			return f
+		case []any:
+			for i := range t {
+				t[i] = convert(t[i])
+			}
+		case map[string]any:
+			for k := range t {
+				t[k] = convert(t[k])
+			}
+		}
+
+		return v
+	}
+
+	return convert(v)
+}
+
+// Returns the normalized JSON representation of a value, so that the same
+// value always compares equal regardless of the format that it was read from.
+func normalizedJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// Decodes a config file in any supported format into its values, by key.
+func decodeConfig(p string, b []byte) (map[string]json.RawMessage, error) {
+	var m map[string]any
+	switch configFormat(p) {
+	case FORMAT_YAML:
+		if err := yaml.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+	case FORMAT_TOML:
+		if err := toml.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+	default:
+		values := map[string]json.RawMessage{}
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil, err
+		}
+
+		// a config of just null has no values
+		if values == nil {
+			values = map[string]json.RawMessage{}
+		}
+
+		return values, nil
+	}
+
+	values := map[string]json.RawMessage{}
+	for k, v := range m {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %v: %v", k, err.Error())
+		}
+
+		values[k] = raw
+	}
+
+	return values, nil
+}
+
+// Encodes config values in the config file's format. The file's old contents
+// are updated in place where possible, so that comments in YAML and TOML
+// configs are kept.
+func encodeConfig(p string, values map[string]json.RawMessage, old []byte) ([]byte, error) {
+	switch configFormat(p) {
+	case FORMAT_YAML:
+		return encodeYAML(values, old)
+	case FORMAT_TOML:
+		if b, ok := updateTOML(values, old); ok {
+			return b, nil
+		}
+
+		return encodeTOML(values)
+	}
+
+	return json.Marshal(values)
+}
+
+// Encodes config values as YAML. If the old contents are a YAML mapping, its
+// nodes are updated in place, so that comments and key order are kept.
+func encodeYAML(values map[string]json.RawMessage, old []byte) ([]byte, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(old, &doc)
+	if err != nil || doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	m := doc.Content[0]
+	seen := map[string]bool{}
+	content := make([]*yaml.Node, 0, len(m.Content))
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k, v := m.Content[i], m.Content[i+1]
+		raw, ok := values[k.Value]
+		if !ok || seen[k.Value] {
+			// a comment below a removed key, such as one at the end of the
+			// file, isn't about that key, so it moves up
+			if k.FootComment != "" && len(content) > 0 {
+				prev := content[len(content)-2]
+				prev.FootComment = strings.TrimPrefix(prev.FootComment+"\n"+k.FootComment, "\n")
+			}
+
+			continue
+		}
+
+		seen[k.Value] = true
+
+		// keep the old node, along with its comments, if the value is the same
+		var oldValue any
+		if v.Decode(&oldValue) != nil || normalizedJSON(oldValue) != normalizedJSON(jsonToAny(raw)) {
+			n := &yaml.Node{}
+			err := n.Encode(jsonToAny(raw))
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode %v: %v", k.Value, err.Error())
+			}
+
+			n.HeadComment, n.LineComment, n.FootComment = v.HeadComment, v.LineComment, v.FootComment
+			v = n
+		}
+
+		content = append(content, k, v)
+	}
+
+	for _, k := range sortedKeys(values) {
+		if seen[k] {
+			continue
+		}
+
+		n := &yaml.Node{}
+		err := n.Encode(jsonToAny(values[k]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %v: %v", k, err.Error())
+		}
+
+		content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, n)
+	}
+
+	m.Content = content
+
+	return yaml.Marshal(&doc)
+}
+
+// Encodes config values as a new TOML file.
+func encodeTOML(values map[string]json.RawMessage) ([]byte, error) {
+	m := map[string]any{}
+	for k, raw := range values {
+		// TOML has no null
+		if v := jsonToAny(raw); v != nil {
+			m[k] = v
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	err := toml.NewEncoder(buf).Encode(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Matches the start of a top-level "key = value" line in a TOML file.
+var tomlKeyLine = regexp.MustCompile(`^\s*"?([A-Za-z0-9_-]+)"?\s*=`)
+
+// Returns the comment at the end of a TOML line, along with the whitespace
+// before it, or "" if the line has no comment. A # inside of a string doesn't
+// start a comment.
+func tomlComment(line string) string {
+	line = strings.TrimRight(line, "\r\n")
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[len(strings.TrimRight(line[:i], " \t")):]
+		}
+	}
+
+	return ""
+}
+
+// Updates a TOML file line by line, so that its comments are kept. This only
+// works when every changed value is a single-line value before the first
+// table; returns false otherwise, and the file has to be encoded from scratch.
+func updateTOML(values map[string]json.RawMessage, old []byte) ([]byte, bool) {
+	var oldValues map[string]any
+	if len(old) == 0 || toml.Unmarshal(old, &oldValues) != nil {
+		return nil, false
+	}
+
+	lines := strings.SplitAfter(string(old), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n"
+	}
+
+	// top-level keys have to come before the first table
+	rootEnd := len(lines)
+	keyLines := map[string]int{}
+	for i, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "[") {
+			rootEnd = i
+			break
+		}
+
+		if match := tomlKeyLine.FindStringSubmatch(l); match != nil {
+			keyLines[match[1]] = i
+		}
+	}
+
+	// and before the comments above it, which belong to the table
+	for rootEnd > 0 && rootEnd < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[rootEnd-1]), "#") {
+		rootEnd--
+	}
+
+	keys := sortedKeys(values)
+	for k := range oldValues {
+		if _, ok := values[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	added := []string{}
+	for _, k := range keys {
+		raw, inNew := values[k]
+		oldValue, inOld := oldValues[k]
+		v := jsonToAny(raw)
+		if inNew && inOld && normalizedJSON(v) == normalizedJSON(oldValue) {
+			continue
+		}
+
+		i, hasLine := keyLines[k]
+		if inOld && !hasLine {
+			// the old value spans multiple lines or is a table
+			return nil, false
+		}
+
+		if hasLine {
+			// make sure that the line holds the whole value
+			var check map[string]any
+			if toml.Unmarshal([]byte(lines[i]), &check) != nil {
+				return nil, false
+			}
+		}
+
+		var line string
+		if inNew && v != nil {
+			switch v.(type) {
+			case []any, map[string]any:
+				return nil, false
+			}
+
+			b, err := encodeTOML(map[string]json.RawMessage{k: raw})
+			if err != nil {
+				return nil, false
+			}
+
+			line = string(b)
+		}
+
+		if hasLine {
+			// keep a comment at the end of a changed line
+			if comment := tomlComment(lines[i]); line != "" && comment != "" {
+				line = strings.TrimSuffix(line, "\n") + comment + "\n"
+			}
+
+			lines[i] = line
+		} else if line != "" {
+			added = append(added, line)
+		}
+	}
+
+	result := strings.Join(lines[:rootEnd], "") + strings.Join(added, "")
+	if rootEnd < len(lines) {
+		if len(added) > 0 {
+			result += "\n"
+		}
+
+		result += strings.Join(lines[rootEnd:], "")
+	}
+
+	// only use the result if it reads back as exactly the right values
+	var check map[string]any
+	if toml.Unmarshal([]byte(result), &check) != nil || len(check) != len(values) {
+		return nil, false
+	}
+
+	for k, raw := range values {
+		if normalizedJSON(check[k]) != normalizedJSON(jsonToAny(raw)) {
+			return nil, false
+		}
+	}
+
+	return []byte(result), true
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Parses a JSON object into config values.
+func rawValues(t *testing.T, s string) map[string]json.RawMessage {
+	t.Helper()
+
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(s), &values); err != nil {
+		t.Fatal(err)
+	}
+
+	return values
+}
+
+// Checks that the encoded config reads back as exactly the values.
+func checkRoundTrip(t *testing.T, p string, b []byte, values map[string]json.RawMessage) {
+	t.Helper()
+
+	got, err := decodeConfig(p, b)
+	if err != nil {
+		t.Fatalf("failed to decode %q: %v", b, err)
+	}
+
+	if len(got) != len(values) {
+		t.Errorf("got %v values, want %v:\n%s", len(got), len(values), b)
+	}
+
+	for k, raw := range values {
+		if normalizedJSON(jsonToAny(got[k])) != normalizedJSON(jsonToAny(raw)) {
+			t.Errorf("got %v = %s, want %s", k, got[k], raw)
+		}
+	}
+}
+
+func TestEncodeYAML(t *testing.T) {
+	old := `# settings for the app
+version: 2
+
+# how many words
+wordCount: 4 # four is enough
+separator: "-" # a dash
+mix: "2:1"
+# end of the settings
+`
+
+	tests := []struct {
+		name   string
+		values string
+		want   string
+	}{
+		{
+			name:   "unchanged",
+			values: `{"version": 2, "wordCount": 4, "separator": "-", "mix": "2:1"}`,
+			want: `# settings for the app
+version: 2
+# how many words
+wordCount: 4 # four is enough
+separator: "-" # a dash
+mix: "2:1"
+# end of the settings
+`,
+		},
+		{
+			name:   "changed values keep their comments",
+			values: `{"version": 2, "wordCount": 6, "separator": "_", "mix": "2:1"}`,
+			want: `# settings for the app
+version: 2
+# how many words
+wordCount: 6 # four is enough
+separator: _ # a dash
+mix: "2:1"
+# end of the settings
+`,
+		},
+		{
+			name:   "added values go at the end",
+			values: `{"version": 2, "wordCount": 4, "separator": "-", "mix": "2:1", "maxLen": 40}`,
+			want: `# settings for the app
+version: 2
+# how many words
+wordCount: 4 # four is enough
+separator: "-" # a dash
+mix: "2:1"
+# end of the settings
+
+maxLen: 40
+`,
+		},
+		{
+			name:   "the comment below a removed value moves up",
+			values: `{"version": 2, "wordCount": 4, "separator": "-"}`,
+			want: `# settings for the app
+version: 2
+# how many words
+wordCount: 4 # four is enough
+separator: "-" # a dash
+# end of the settings
+`,
+		},
+		{
+			name:   "a list",
+			values: `{"version": 2, "wordCount": 4, "separator": "-", "mix": "2:1", "attackers": [{"name": "gpu", "guessesPerSecond": 1e10}]}`,
+			want: `# settings for the app
+version: 2
+# how many words
+wordCount: 4 # four is enough
+separator: "-" # a dash
+mix: "2:1"
+# end of the settings
+
+attackers:
+    - guessesPerSecond: 1e+10
+      name: gpu
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := rawValues(t, tt.values)
+			b, err := encodeConfig("config.yaml", values, []byte(old))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", b, tt.want)
+			}
+
+			checkRoundTrip(t, "config.yaml", b, values)
+		})
+	}
+
+	// a file that isn't a mapping is replaced
+	values := rawValues(t, `{"version": 2, "wordCount": 5}`)
+	b, err := encodeConfig("config.yaml", values, []byte("- 1\n- 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoundTrip(t, "config.yaml", b, values)
+}
+
+func TestUpdateTOML(t *testing.T) {
+	old := `# settings for the app
+version = 2
+wordCount = 4 # four is enough
+separator = "-#-" # dashes, with a "#"
+mix = '2:1'
+
+# a custom attacker
+[[attackers]]
+name = "gpu"
+guessesPerSecond = 1e10
+`
+
+	attackers := `"attackers": [{"name": "gpu", "guessesPerSecond": 1e10}]`
+
+	tests := []struct {
+		name   string
+		values string
+		// empty if the file has to be encoded from scratch
+		want string
+	}{
+		{
+			name:   "unchanged",
+			values: `{"version": 2, "wordCount": 4, "separator": "-#-", "mix": "2:1", ` + attackers + `}`,
+			want:   old,
+		},
+		{
+			name:   "changed values keep their comments",
+			values: `{"version": 2, "wordCount": 6, "separator": "_#_", "mix": "1:1", ` + attackers + `}`,
+			want: `# settings for the app
+version = 2
+wordCount = 6 # four is enough
+separator = "_#_" # dashes, with a "#"
+mix = "1:1"
+
+# a custom attacker
+[[attackers]]
+name = "gpu"
+guessesPerSecond = 1e10
+`,
+		},
+		{
+			name:   "added values go before the first table",
+			values: `{"version": 2, "wordCount": 4, "separator": "-#-", "mix": "2:1", "maxLen": 40, ` + attackers + `}`,
+			want: `# settings for the app
+version = 2
+wordCount = 4 # four is enough
+separator = "-#-" # dashes, with a "#"
+mix = '2:1'
+
+maxLen = 40
+
+# a custom attacker
+[[attackers]]
+name = "gpu"
+guessesPerSecond = 1e10
+`,
+		},
+		{
+			name:   "removed values",
+			values: `{"version": 2, "separator": "-#-", ` + attackers + `}`,
+			want: `# settings for the app
+version = 2
+separator = "-#-" # dashes, with a "#"
+
+# a custom attacker
+[[attackers]]
+name = "gpu"
+guessesPerSecond = 1e10
+`,
+		},
+		{
+			name:   "a key in a table changes",
+			values: `{"version": 2, "wordCount": 4, "separator": "-#-", "mix": "2:1", "attackers": [{"name": "cpu", "guessesPerSecond": 1e10}]}`,
+		},
+		{
+			name:   "the table is removed",
+			values: `{"version": 2, "wordCount": 4, "separator": "-#-", "mix": "2:1"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := rawValues(t, tt.values)
+			b, ok := updateTOML(values, []byte(old))
+			if ok != (tt.want != "") {
+				t.Fatalf("got ok %v, want %v:\n%s", ok, tt.want != "", b)
+			}
+
+			if ok && string(b) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", b, tt.want)
+			}
+
+			b, err := encodeConfig("config.toml", values, []byte(old))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkRoundTrip(t, "config.toml", b, values)
+		})
+	}
+
+	// a value that spans several lines can't be updated line by line
+	multiline := "version = 2\nseparator = \"\"\"\n-\"\"\"\nwordCount = 4\n"
+	values := rawValues(t, `{"version": 2, "separator": "+", "wordCount": 4}`)
+	if b, ok := updateTOML(values, []byte(multiline)); ok {
+		t.Errorf("updated a multi-line value in place:\n%s", b)
+	}
+
+	b, err := encodeConfig("config.toml", values, []byte(multiline))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRoundTrip(t, "config.toml", b, values)
+}
+
+func TestTOMLComment(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"wordCount = 4\n", ""},
+		{"wordCount = 4 # four\n", " # four"},
+		{"wordCount = 4\t# four\r\n", "\t# four"},
+		{`separator = "#" # a hash` + "\n", " # a hash"},
+		{`separator = "\"#" # quote and hash`, " # quote and hash"},
+		{`separator = '\' # backslash`, " # backslash"},
+		{`separator = "a#b"`, ""},
+		{`"wordCount" = 4 #`, " #"},
+	}
+
+	for _, tt := range tests {
+		if got := tomlComment(tt.line); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
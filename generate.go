@@ -0,0 +1,488 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	mrand "math/rand/v2"
+)
+
+const (
+	// Prevents the CPU from endlessly attempting to fulfill unrealistic
+	// password generation parameters, same as the diceware lib.
+	maxAttempts = 20000
+	// Words outside of these lengths are never chosen, same as the diceware
+	// lib.
+	maxWordLength = 16
+	minWordLength = 4
+	// Number of characters that are appended to every generated password: one
+	// digit and one symbol.
+	suffixLength = 2
+)
+
+// Symbols that may be appended to generated passwords. Unlike the diceware
+// lib, every symbol and every digit can be chosen, so that the entropy
+// calculation is exact.
+var symbols = []string{"!", "@", "#", "$", "%", "*", "/", "?", ".", ","}
+
+// Mix presets that are offered in the UI.
+var mixPresets = []string{MIX_SIMPLE, MIX_EXTENDED, "2:1", "1:1", "1:2", MIX_UNION}
+
+// Named mixes; any other mix is written as "simple:extended", i.e. "2:1".
+const (
+	MIX_SIMPLE   = "simple"
+	MIX_EXTENDED = "extended"
+	MIX_UNION    = "union"
+)
+
+// WordLists stores the loaded words, already filtered down to the words that
+// are eligible to be chosen. The union list is only populated when a union mix
+// is in use.
+type WordLists struct {
+	Simple  []string
+	Complex []string
+	Union   []string
+}
+
+// Mix describes how words are drawn across the simple and extended lists.
+//
+// For a ratio such as 2:1, words are drawn in a repeating pattern of 2 simple
+// words followed by 1 extended word. For a union, every word is drawn from the
+// combined, de-duplicated simple and extended lists.
+type Mix struct {
+	Simple   int
+	Extended int
+	Union    bool
+}
+
+// Parses a mix from its config representation: "simple", "extended", "union"
+// or "simple:extended" such as "2:1".
+func parseMix(s string) (Mix, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case MIX_SIMPLE, "":
+		return Mix{Simple: 1}, nil
+	case MIX_EXTENDED:
+		return Mix{Extended: 1}, nil
+	case MIX_UNION:
+		return Mix{Union: true}, nil
+	}
+
+	sv, ev, ok := strings.Cut(s, ":")
+	if !ok {
+		return Mix{}, fmt.Errorf("mix %q must be simple, extended, union or a ratio like 2:1", s)
+	}
+
+	si, err := strconv.Atoi(strings.TrimSpace(sv))
+	if err != nil || si < 0 {
+		return Mix{}, fmt.Errorf("mix %q has an invalid simple word count", s)
+	}
+
+	ei, err := strconv.Atoi(strings.TrimSpace(ev))
+	if err != nil || ei < 0 {
+		return Mix{}, fmt.Errorf("mix %q has an invalid extended word count", s)
+	}
+
+	if si+ei == 0 {
+		return Mix{}, fmt.Errorf("mix %q must draw at least one word", s)
+	}
+
+	return Mix{Simple: si, Extended: ei}, nil
+}
+
+// Returns the config representation of the mix.
+func (m Mix) String() string {
+	switch {
+	case m.Union:
+		return MIX_UNION
+	case m.Extended == 0:
+		return MIX_SIMPLE
+	case m.Simple == 0:
+		return MIX_EXTENDED
+	}
+
+	return fmt.Sprintf("%v:%v", m.Simple, m.Extended)
+}
+
+// Returns true if the extended word list has to be loaded for this mix.
+func (m Mix) needsComplex() bool {
+	return m.Union || m.Extended > 0
+}
+
+// Returns the list of candidate words for each of the n word positions.
+func (m Mix) pools(words *WordLists, n int) [][]string {
+	result := make([][]string, n)
+	for i := range result {
+		switch {
+		case m.Union:
+			result[i] = words.Union
+		case i%(m.Simple+m.Extended) < m.Simple:
+			result[i] = words.Simple
+		default:
+			result[i] = words.Complex
+		}
+	}
+
+	return result
+}
+
+// Returns a uniformly random number from 0 to n-1, read from the given source
+// of randomness.
+func randInt(r io.Reader, n int) (int, error) {
+	k, err := rand.Int(r, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read from random source: %v", err.Error())
+	}
+
+	return int(k.Int64()), nil
+}
+
+// Returns a deterministic source of randomness derived from the seed. This is
+// only meant for tests and for reproducing bug reports - passwords generated
+// from it are NOT secure.
+func seededReader(seed string) io.Reader {
+	return mrand.NewChaCha8(sha256.Sum256([]byte(seed)))
+}
+
+// Returns true if the word is eligible to be chosen, based on its length.
+func eligible(w string) bool {
+	l := utf8.RuneCountInString(w)
+
+	return l >= minWordLength && l <= maxWordLength
+}
+
+// Returns the de-duplicated union of the two word lists.
+func unionWords(a, b []string) []string {
+	seen := make(map[string]struct{}, len(a)+len(b))
+	result := make([]string, 0, len(a)+len(b))
+	for _, list := range [][]string{a, b} {
+		for _, w := range list {
+			if _, ok := seen[w]; ok {
+				continue
+			}
+
+			seen[w] = struct{}{}
+			result = append(result, w)
+		}
+	}
+
+	return result
+}
+
+// Draw holds the random choices that make up a single generated password.
+type Draw struct {
+	// The index of the chosen word in its pool, for each word position
+	Words []int
+	// The index of the chosen digit, 0-9
+	Digit int
+	// The index of the chosen symbol in symbols
+	Symbol int
+}
+
+// Makes the random choices for a password: one word from each pool, followed
+// by a digit and a symbol. Choices whose words and separators don't fit within
+// minLen and maxLen (along with the digit and symbol) are discarded and drawn
+// again. All randomness is read from r, which is normally crypto/rand.Reader.
+func drawPassword(r io.Reader, pools [][]string, s string, maxLen int, minLen int) (Draw, error) {
+	d := Draw{Words: make([]int, len(pools))}
+	fixed := utf8.RuneCountInString(s)*(len(pools)-1) + suffixLength
+
+	startTime := time.Now()
+	for attempts := 1; attempts <= maxAttempts; attempts++ {
+		// abort if the operation has taken longer than 1 second
+		if startTime.Add(1 * time.Second).Before(time.Now()) {
+			return d, fmt.Errorf("operation took too long, canceling")
+		}
+
+		l := fixed
+		for i, pool := range pools {
+			k, err := randInt(r, len(pool))
+			if err != nil {
+				return d, err
+			}
+
+			d.Words[i] = k
+			l += utf8.RuneCountInString(pool[k])
+		}
+
+		if l > maxLen || l < minLen {
+			continue
+		}
+
+		var err error
+		d.Digit, err = randInt(r, 10)
+		if err != nil {
+			return d, err
+		}
+
+		d.Symbol, err = randInt(r, len(symbols))
+		if err != nil {
+			return d, err
+		}
+
+		return d, nil
+	}
+
+	return d, fmt.Errorf("exceeded maximum attempts to generate password; try adjusting the min/max length or word count")
+}
+
+// Formats the drawn choices as a password: the words separated by s, followed
+// by the digit and symbol, with the first letter capitalized.
+func (d Draw) format(pools [][]string, s string) string {
+	sb := new(strings.Builder)
+	for i, k := range d.Words {
+		sb.WriteString(pools[i][k])
+		// don't put the separator after the last word
+		if i != len(d.Words)-1 {
+			sb.WriteString(s)
+		}
+	}
+
+	sb.WriteString(strconv.Itoa(d.Digit))
+	sb.WriteString(symbols[d.Symbol])
+
+	// capitalize the first letter
+	result := []rune(sb.String())
+	result[0] = unicode.ToUpper(result[0])
+
+	return string(result)
+}
+
+// Returns the word pools for the settings, or an error if any of them are
+// empty.
+func checkedPools(words *WordLists, m Mix, n int) ([][]string, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("word count must be at least 1")
+	}
+
+	pools := m.pools(words, n)
+	for i, pool := range pools {
+		if len(pool) == 0 {
+			return nil, fmt.Errorf("no words available for word %v with mix %v", i+1, m)
+		}
+	}
+
+	return pools, nil
+}
+
+// Generates a password according to the requirements: n words drawn according
+// to the mix and separated by s, followed by a digit and a symbol, with the
+// first letter capitalized. Lengths are counted in characters, not bytes.
+// Passwords that don't fit within minLen and maxLen are discarded and
+// regenerated. All randomness is read from r, which is normally
+// crypto/rand.Reader.
+func generatePassword(r io.Reader, words *WordLists, m Mix, n int, s string, maxLen int, minLen int) (string, error) {
+	pools, err := checkedPools(words, m, n)
+	if err != nil {
+		return "", err
+	}
+
+	d, err := drawPassword(r, pools, s, maxLen, minLen)
+	if err != nil {
+		return "", err
+	}
+
+	return d.format(pools, s), nil
+}
+
+// Returns the number of words of each length in the pool.
+func lengthHistogram(pool []string) map[int]int64 {
+	hist := map[int]int64{}
+	for _, w := range pool {
+		hist[utf8.RuneCountInString(w)]++
+	}
+
+	return hist
+}
+
+// Returns the number of combinations of one word from each pool, by the total
+// length of the words.
+func lengthCounts(pools [][]string) map[int]*big.Int {
+	// counts[l] is the number of combinations of the words so far whose total
+	// length is l
+	counts := map[int]*big.Int{0: big.NewInt(1)}
+	// word length histograms, calculated once per word list
+	hists := map[*string]map[int]int64{}
+	for _, pool := range pools {
+		if len(pool) == 0 {
+			return map[int]*big.Int{}
+		}
+
+		hist, ok := hists[&pool[0]]
+		if !ok {
+			hist = lengthHistogram(pool)
+			hists[&pool[0]] = hist
+		}
+
+		next := map[int]*big.Int{}
+		for l, c := range counts {
+			for wl, wc := range hist {
+				v, ok := next[l+wl]
+				if !ok {
+					v = new(big.Int)
+					next[l+wl] = v
+				}
+
+				v.Add(v, new(big.Int).Mul(c, big.NewInt(wc)))
+			}
+		}
+
+		counts = next
+	}
+
+	return counts
+}
+
+// Counts the number of word combinations that satisfy the length
+// requirements. Since non-conforming passwords are discarded, every one of
+// these combinations is equally likely to be generated.
+func countCombinations(words *WordLists, m Mix, n int, s string, maxLen int, minLen int) *big.Int {
+	if n < 1 {
+		return big.NewInt(0)
+	}
+
+	fixed := utf8.RuneCountInString(s)*(n-1) + suffixLength
+	total := new(big.Int)
+	for l, c := range lengthCounts(m.pools(words, n)) {
+		if l+fixed <= maxLen && l+fixed >= minLen {
+			total.Add(total, c)
+		}
+	}
+
+	return total
+}
+
+// Returns the base-2 logarithm of a big integer.
+func log2Big(x *big.Int) float64 {
+	if x.Sign() <= 0 {
+		return math.Inf(-1)
+	}
+
+	mant := new(big.Float).SetInt(x)
+	exp := mant.MantExp(mant)
+	f, _ := mant.Float64()
+
+	return math.Log2(f) + float64(exp)
+}
+
+// Calculates the exact entropy, in bits, of passwords generated with the
+// given settings. Returns 0 if no password can satisfy the settings.
+func entropy(words *WordLists, m Mix, n int, s string, maxLen int, minLen int) float64 {
+	c := countCombinations(words, m, n, s, maxLen, minLen)
+	if c.Sign() <= 0 {
+		return 0
+	}
+
+	return log2Big(c) + math.Log2(10) + math.Log2(float64(len(symbols)))
+}
+
+// Calculates the exact entropy, in bits, of passwords generated with the
+// current settings.
+func (app *App) entropy() float64 {
+	return entropy(&app.words, app.mix, app.conf.WordCount, app.conf.Separator, app.conf.MaxLen, app.conf.MinLen)
+}
+
+// Generates a password with the current settings, regenerating it if it's
+// found in the breach data. Refuses to generate passwords if the settings
+// don't meet the minimum entropy. Any errors are logged.
+func (app *App) generate() (string, error) {
+	err := app.checkEntropyFloor()
+	if err != nil {
+		log.Printf("refusing to generate password: %v", err.Error())
+		return "", err
+	}
+
+	for i := 0; ; i++ {
+		r, err := generatePassword(app.random, &app.words, app.mix, app.conf.WordCount, app.conf.Separator, app.conf.MaxLen, app.conf.MinLen)
+		if err != nil {
+			log.Printf("failed to generate password: %v", err.Error())
+			return r, err
+		}
+
+		n, err := app.breached(r)
+		if err != nil {
+			log.Printf("failed to check password against breach data: %v", err.Error())
+			return "", err
+		}
+
+		if n == 0 {
+			app.recordGenerated(r)
+			return r, nil
+		}
+
+		// never log the password itself
+		Logf("generated password was found in breach data %v times, regenerating", n)
+		if i >= maxBreachRegenerations {
+			return "", fmt.Errorf("every generated password was found in breach data after %v attempts; check the breach data file", i+1)
+		}
+	}
+}
+
+// The output of the gen subcommand with -json.
+type genOutput struct {
+	Passwords []string `json:"passwords"`
+	// the exact entropy of the current settings, in bits
+	Entropy float64 `json:"entropy"`
+	// the average time to crack a password generated with the current
+	// settings, for each attacker model
+	CrackTimes []CrackTime `json:"crackTimes"`
+}
+
+// Runs the gen subcommand, which prints passwords generated with the current
+// settings without opening the UI. Returns the process exit code.
+func (app *App) genCommand(args []string) int {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	count := fs.Int("n", 1, "the number of passwords to generate")
+	asJSON := fs.Bool("json", false, "print the passwords, entropy and crack times as JSON")
+	_ = fs.Parse(args)
+
+	if *count < 1 {
+		fmt.Fprintf(fs.Output(), "-n must be at least 1, not %v\n", *count)
+		fs.Usage()
+		return 2
+	}
+
+	out := genOutput{Passwords: make([]string, 0, *count)}
+	for i := 0; i < *count; i++ {
+		r, err := app.generate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to generate password: %v\n", err.Error())
+			return 1
+		}
+
+		out.Passwords = append(out.Passwords, r)
+	}
+
+	if !*asJSON {
+		for _, p := range out.Passwords {
+			fmt.Fprintln(os.Stdout, p)
+		}
+
+		return 0
+	}
+
+	out.Entropy = app.entropy()
+	out.CrackTimes = app.crackTimes()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode output: %v\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
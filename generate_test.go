@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Rewrites the golden files instead of comparing against them, i.e.
+// `go test -run Golden -update`.
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// Word lists for the tests, so that they don't depend on the embedded lists.
+// The extended list shares a few words with the simple list, so that the union
+// is smaller than both lists combined.
+var testWords = WordLists{
+	Simple: []string{
+		"acorn", "badge", "cabin", "daisy", "eagle", "fable", "gecko", "harbor",
+		"igloo", "jelly", "kayak", "lemon", "mango", "nectar", "olive", "pepper",
+	},
+	Complex: []string{
+		"abacus", "bramble", "chimera", "dervish", "ephemeral", "filigree",
+		"gossamer", "halcyon", "iridescent", "juxtapose", "kaleidoscope",
+		"labyrinth", "acorn", "mango", "olive", "quixotic",
+	},
+}
+
+func init() {
+	testWords.Union = unionWords(testWords.Simple, testWords.Complex)
+}
+
+// Generates passwords from a fixed seed for every mix preset, and compares
+// them against testdata/generate.golden. Any change to how randomness is
+// turned into passwords changes the output, so this has to be updated
+// deliberately with -update.
+func TestGenerateGolden(t *testing.T) {
+	sb := new(strings.Builder)
+	for _, preset := range mixPresets {
+		m, err := parseMix(preset)
+		if err != nil {
+			t.Fatalf("preset %v: %v", preset, err.Error())
+		}
+
+		r := seededReader("golden " + preset)
+		for i := 0; i < 3; i++ {
+			p, err := generatePassword(r, &testWords, m, 4, "-", 64, 20)
+			if err != nil {
+				t.Fatalf("preset %v: %v", preset, err.Error())
+			}
+
+			fmt.Fprintf(sb, "%v\t%v\n", preset, p)
+		}
+	}
+
+	golden := filepath.Join("testdata", "generate.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(sb.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err.Error())
+	}
+
+	if got := sb.String(); got != string(want) {
+		t.Errorf("generated passwords don't match %v:\ngot:\n%v\nwant:\n%v", golden, got, string(want))
+	}
+}
+
+// The same seed must always give the same passwords, and different seeds
+// different passwords.
+func TestSeededReaderDeterministic(t *testing.T) {
+	m := Mix{Simple: 1}
+	gen := func(seed string) string {
+		p, err := generatePassword(seededReader(seed), &testWords, m, 4, " ", 64, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return p
+	}
+
+	if a, b := gen("a"), gen("a"); a != b {
+		t.Errorf("same seed gave %q and %q", a, b)
+	}
+
+	if a, b := gen("a"), gen("b"); a == b {
+		t.Errorf("different seeds both gave %q", a)
+	}
+}
@@ -3,12 +3,14 @@ module go-fltk-diceware
 go 1.23.4
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/adrg/xdg v0.5.0
 	github.com/atotto/clipboard v0.1.4
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/pwiecz/go-fltk v0.0.0-20240525043121-5313f8a5a643
+	golang.org/x/term v0.22.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/charles-m-knox/go-dicewarelib v0.0.1
-	golang.org/x/sys v0.22.0 // indirect
-)
+require golang.org/x/sys v0.22.0 // indirect
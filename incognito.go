@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pwiecz/go-fltk"
+)
+
+// Title of the main window.
+const APP_TITLE = "Diceware Password Generator FLTK"
+
+// Returns the title of the main window, which shows when incognito mode is on.
+func (app *App) title() string {
+	if app.incognito {
+		return fmt.Sprintf("%v [Incognito - nothing is saved]", APP_TITLE)
+	}
+
+	return APP_TITLE
+}
+
+// Turns on incognito mode for the rest of the session: nothing is written to
+// disk from then on, including the config. It can't be turned off again, so
+// that nothing from the incognito part of the session is saved later.
+func (app *App) enterIncognito() {
+	if app.incognito {
+		fltk.MessageBox("Incognito", "Incognito mode is already on. Nothing will be saved until the app is restarted.")
+		return
+	}
+
+	app.incognito = true
+	app.cancelPendingSave()
+	app.ui.win.SetLabel(app.title())
+	app.ui.log.SetValue("Incognito mode is on: nothing will be saved for the rest of this session")
+	Log("incognito mode enabled, nothing will be saved for the rest of this session")
+}
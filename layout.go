@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math"
+)
+
+// A widget's position and size, in pixels.
+type rect struct {
+	X int
+	Y int
+	W int
+	H int
+}
+
+// The kinds of layout nodes.
+type layoutKind int
+
+const (
+	// a named widget, which takes up the whole space that it's given
+	nodeWidget layoutKind = iota
+	// empty space, such as the margins and the room for labels above inputs
+	nodeSpacer
+	// children side by side, splitting the width by weight
+	nodeRow
+	// children stacked on top of each other, splitting the height by weight
+	nodeColumn
+	// children filling a grid row by row, with weighted tracks and gaps
+	nodeGrid
+)
+
+// layoutNode is a declarative description of where widgets go, relative to the
+// space that the node is given. Sizes are weights rather than pixels, so the
+// same description fits any window size.
+type layoutNode struct {
+	kind layoutKind
+	// the widget's name, for widget nodes
+	name string
+	// the node's share of its parent's width (in a row) or height (in a
+	// column), relative to its siblings; ignored in grids
+	weight   float64
+	children []layoutNode
+	// for grids, the relative widths of the columns and heights of the rows,
+	// and the gaps between them in the same units; rows without a weight get
+	// a weight of 1
+	colWeights []float64
+	rowWeights []float64
+	colGap     float64
+	rowGap     float64
+}
+
+// Returns a node for the named widget.
+func place(name string, weight float64) layoutNode {
+	return layoutNode{kind: nodeWidget, name: name, weight: weight}
+}
+
+// Returns a node for empty space.
+func gap(weight float64) layoutNode {
+	return layoutNode{kind: nodeSpacer, weight: weight}
+}
+
+// Returns a node that places its children side by side.
+func hbox(weight float64, children ...layoutNode) layoutNode {
+	return layoutNode{kind: nodeRow, weight: weight, children: children}
+}
+
+// Returns a node that stacks its children on top of each other.
+func vbox(weight float64, children ...layoutNode) layoutNode {
+	return layoutNode{kind: nodeColumn, weight: weight, children: children}
+}
+
+// Returns a node that places its children in a grid with one column per column
+// weight, filling it row by row.
+func grid(weight float64, colWeights []float64, colGap float64, rowWeights []float64, rowGap float64, children ...layoutNode) layoutNode {
+	return layoutNode{
+		kind:       nodeGrid,
+		weight:     weight,
+		children:   children,
+		colWeights: colWeights,
+		rowWeights: rowWeights,
+		colGap:     colGap,
+		rowGap:     rowGap,
+	}
+}
+
+// Splits a length into parts proportional to the weights. The boundaries are
+// rounded rather than each part, so the parts always add up to exactly the
+// length, without gaps or overlaps. Each part's X and W are its start and size
+// along the split axis.
+func split(start, length int, weights []float64) []rect {
+	total := 0.0
+	for _, w := range weights {
+		total += max(w, 0)
+	}
+
+	parts := make([]rect, len(weights))
+	if total <= 0 {
+		return parts
+	}
+
+	cum := 0.0
+	prev := start
+	for i, w := range weights {
+		cum += max(w, 0)
+		end := start + int(math.Round(float64(length)*cum/total))
+		parts[i] = rect{X: prev, W: end - prev}
+		prev = end
+	}
+
+	return parts
+}
+
+// Interleaves the track weights with gaps, for splitting a grid's width or
+// height. Track i ends up at index 2*i.
+func withGaps(tracks []float64, gap float64) []float64 {
+	result := make([]float64, 0, len(tracks)*2)
+	for i, t := range tracks {
+		if i > 0 {
+			result = append(result, gap)
+		}
+
+		result = append(result, t)
+	}
+
+	return result
+}
+
+// Computes the geometry of every widget in the node, within r, and adds it to
+// out by widget name.
+func (n layoutNode) layout(r rect, out map[string]rect) {
+	switch n.kind {
+	case nodeWidget:
+		out[n.name] = r
+	case nodeRow, nodeColumn:
+		weights := make([]float64, len(n.children))
+		for i, c := range n.children {
+			weights[i] = c.weight
+		}
+
+		if n.kind == nodeRow {
+			for i, s := range split(r.X, r.W, weights) {
+				n.children[i].layout(rect{X: s.X, Y: r.Y, W: s.W, H: r.H}, out)
+			}
+		} else {
+			for i, s := range split(r.Y, r.H, weights) {
+				n.children[i].layout(rect{X: r.X, Y: s.X, W: r.W, H: s.W}, out)
+			}
+		}
+	case nodeGrid:
+		cols := len(n.colWeights)
+		if cols == 0 {
+			return
+		}
+
+		rows := (len(n.children) + cols - 1) / cols
+		rowWeights := make([]float64, rows)
+		for i := range rowWeights {
+			rowWeights[i] = 1
+			if i < len(n.rowWeights) {
+				rowWeights[i] = n.rowWeights[i]
+			}
+		}
+
+		xs := split(r.X, r.W, withGaps(n.colWeights, n.colGap))
+		ys := split(r.Y, r.H, withGaps(rowWeights, n.rowGap))
+		for i, c := range n.children {
+			x, y := xs[2*(i%cols)], ys[2*(i/cols)]
+			c.layout(rect{X: x.X, Y: y.X, W: x.W, H: y.W}, out)
+		}
+	}
+}
+
+// breakpoint is a layout that is used for windows up to a given aspect ratio
+// (width divided by height).
+type breakpoint struct {
+	name      string
+	maxAspect float64
+	root      layoutNode
+}
+
+// Returns the layout for a window of the given size: the named layout if one
+// is forced, or else the first layout whose maximum aspect ratio fits the
+// window. The layouts must be sorted by their maximum aspect ratio, and the
+// last one is used for anything wider.
+func chooseLayout(layouts []breakpoint, w, h int, force string) breakpoint {
+	for _, b := range layouts {
+		if b.name == force {
+			return b
+		}
+	}
+
+	aspect := math.Inf(1)
+	if h > 0 {
+		aspect = float64(w) / float64(h)
+	}
+
+	for _, b := range layouts {
+		if aspect <= b.maxAspect {
+			return b
+		}
+	}
+
+	return layouts[len(layouts)-1]
+}
+
+// Computes the geometry of every widget for a window of the given size, and
+// returns the name of the layout that was used.
+func computeLayout(layouts []breakpoint, w, h int, force string) (string, map[string]rect) {
+	b := chooseLayout(layouts, w, h, force)
+	out := map[string]rect{}
+	b.root.layout(rect{W: w, H: h}, out)
+
+	return b.name, out
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   int
+		length  int
+		weights []float64
+		want    []rect
+	}{
+		{"even", 0, 90, []float64{1, 1, 1}, []rect{{X: 0, W: 30}, {X: 30, W: 30}, {X: 60, W: 30}}},
+		{"offset", 10, 30, []float64{2, 1}, []rect{{X: 10, W: 20}, {X: 30, W: 10}}},
+		{"rounded boundaries", 0, 10, []float64{1, 1, 1}, []rect{{X: 0, W: 3}, {X: 3, W: 4}, {X: 7, W: 3}}},
+		{"zero weight", 0, 10, []float64{1, 0, 1}, []rect{{X: 0, W: 5}, {X: 5, W: 0}, {X: 5, W: 5}}},
+		{"negative weight counts as zero", 0, 10, []float64{1, -5, 1}, []rect{{X: 0, W: 5}, {X: 5, W: 0}, {X: 5, W: 5}}},
+		{"no weight", 5, 10, []float64{0, 0}, []rect{{}, {}}},
+		{"nothing to split", 0, 10, nil, []rect{}},
+		{"zero length", 3, 0, []float64{1, 2}, []rect{{X: 3, W: 0}, {X: 3, W: 0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := split(tt.start, tt.length, tt.weights)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// However the weights divide the length, the parts must be contiguous and add
+// up to exactly the length.
+func TestSplitSumsToLength(t *testing.T) {
+	weightSets := [][]float64{
+		{1, 1, 1},
+		{1, 2, 3, 4, 5, 6, 7},
+		{0.1, 0.7, 0.2},
+		{5, 90, 5},
+		{1e-9, 1, 1e9},
+		{3, 0, 3, -1, 3},
+	}
+
+	for _, weights := range weightSets {
+		for length := 0; length <= 1000; length += 7 {
+			parts := split(13, length, weights)
+			pos, total := 13, 0
+			for i, p := range parts {
+				if p.X != pos || p.W < 0 {
+					t.Fatalf("weights %v, length %v: part %v is %+v, expected it to start at %v", weights, length, i, p, pos)
+				}
+
+				pos += p.W
+				total += p.W
+			}
+
+			if total != length {
+				t.Fatalf("weights %v, length %v: parts add up to %v", weights, length, total)
+			}
+		}
+	}
+}
+
+func TestGridLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		node layoutNode
+		r    rect
+		want map[string]rect
+	}{
+		{
+			name: "two by two with gaps weighted like tracks",
+			node: grid(1, []float64{2, 2}, 1, []float64{1, 1}, 0.5, place("a", 1), place("b", 1), place("c", 1), place("d", 1)),
+			r:    rect{X: 10, Y: 20, W: 50, H: 25},
+			want: map[string]rect{
+				"a": {X: 10, Y: 20, W: 20, H: 10},
+				"b": {X: 40, Y: 20, W: 20, H: 10},
+				"c": {X: 10, Y: 35, W: 20, H: 10},
+				"d": {X: 40, Y: 35, W: 20, H: 10},
+			},
+		},
+		{
+			name: "missing row weights default to 1, and the last row can be partial",
+			node: grid(1, []float64{1, 3}, 0, []float64{2}, 0, place("a", 1), place("b", 1), place("c", 1)),
+			r:    rect{W: 40, H: 30},
+			want: map[string]rect{
+				"a": {X: 0, Y: 0, W: 10, H: 20},
+				"b": {X: 10, Y: 0, W: 30, H: 20},
+				"c": {X: 0, Y: 20, W: 10, H: 10},
+			},
+		},
+		{
+			name: "child weights are ignored",
+			node: grid(1, []float64{1, 1}, 0, nil, 0, place("a", 5), place("b", 0)),
+			r:    rect{W: 20, H: 10},
+			want: map[string]rect{
+				"a": {X: 0, Y: 0, W: 10, H: 10},
+				"b": {X: 10, Y: 0, W: 10, H: 10},
+			},
+		},
+		{
+			name: "zero and negative track weights collapse",
+			node: grid(1, []float64{1, 0, -1, 1}, 0, []float64{0, 1}, 0,
+				place("a", 1), place("b", 1), place("c", 1), place("d", 1), place("e", 1)),
+			r: rect{W: 20, H: 10},
+			want: map[string]rect{
+				"a": {X: 0, Y: 0, W: 10, H: 0},
+				"b": {X: 10, Y: 0, W: 0, H: 0},
+				"c": {X: 10, Y: 0, W: 0, H: 0},
+				"d": {X: 10, Y: 0, W: 10, H: 0},
+				"e": {X: 0, Y: 0, W: 10, H: 10},
+			},
+		},
+		{
+			name: "no columns places nothing",
+			node: grid(1, nil, 0, nil, 0, place("a", 1)),
+			r:    rect{W: 20, H: 10},
+			want: map[string]rect{},
+		},
+		{
+			name: "nested in rows and columns",
+			node: vbox(1, gap(1), hbox(2, gap(1), grid(2, []float64{1, 1}, 0, nil, 0, place("a", 1), place("b", 1)), gap(1)), gap(1)),
+			r:    rect{W: 40, H: 40},
+			want: map[string]rect{
+				"a": {X: 10, Y: 10, W: 10, H: 20},
+				"b": {X: 20, Y: 10, W: 10, H: 20},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]rect{}
+			tt.node.layout(tt.r, got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChooseLayout(t *testing.T) {
+	layouts := []breakpoint{
+		{name: "tall", maxAspect: 0.5},
+		{name: "portrait", maxAspect: 1},
+		{name: "wide", maxAspect: math.Inf(1)},
+	}
+
+	tests := []struct {
+		name  string
+		w, h  int
+		force string
+		want  string
+	}{
+		{"very tall", 100, 300, "", "tall"},
+		{"at a breakpoint", 100, 200, "", "tall"},
+		{"just past a breakpoint", 101, 200, "", "portrait"},
+		{"square", 100, 100, "", "portrait"},
+		{"wide", 300, 100, "", "wide"},
+		{"zero height", 100, 0, "", "wide"},
+		{"zero size", 0, 0, "", "wide"},
+		{"zero width", 0, 100, "", "tall"},
+		{"forced", 300, 100, "tall", "tall"},
+		{"forced with zero height", 100, 0, "portrait", "portrait"},
+		{"forced layout that doesn't exist", 100, 300, "landscape", "tall"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chooseLayout(layouts, tt.w, tt.h, tt.force); got.name != tt.want {
+				t.Errorf("got %v, want %v", got.name, tt.want)
+			}
+		})
+	}
+
+	// if no breakpoint fits, the last (widest) layout is used
+	narrow := []breakpoint{{name: "a", maxAspect: 0.5}, {name: "b", maxAspect: 1}}
+	if got := chooseLayout(narrow, 300, 100, ""); got.name != "b" {
+		t.Errorf("got %v for a window wider than every breakpoint, want b", got.name)
+	}
+}
+
+// Every layout of the app must place every widget inside the window, without
+// overlapping any other widget.
+func TestUILayouts(t *testing.T) {
+	widgets := (&UI{}).layoutWidgets()
+	sizes := [][2]int{{300, 900}, {270, 390}, {450, 315}, {900, 200}, {1, 1}, {2000, 1000}}
+	for _, b := range uiLayouts {
+		for _, size := range sizes {
+			w, h := size[0], size[1]
+			name, rects := computeLayout(uiLayouts, w, h, b.name)
+			if name != b.name {
+				t.Fatalf("forcing %v used %v", b.name, name)
+			}
+
+			for k := range widgets {
+				r, ok := rects[k]
+				if !ok {
+					t.Errorf("%v layout at %vx%v doesn't place %v", name, w, h, k)
+					continue
+				}
+
+				if r.X < 0 || r.Y < 0 || r.W < 0 || r.H < 0 || r.X+r.W > w || r.Y+r.H > h {
+					t.Errorf("%v layout at %vx%v places %v outside of the window: %+v", name, w, h, k, r)
+				}
+			}
+
+			for k := range rects {
+				if _, ok := widgets[k]; !ok {
+					t.Errorf("%v layout places %v, which isn't a widget", name, k)
+				}
+
+				for other := range rects {
+					a, o := rects[k], rects[other]
+					if k < other && a.X < o.X+o.W && o.X < a.X+a.W && a.Y < o.Y+o.H && o.Y < a.Y+a.H {
+						t.Errorf("%v layout at %vx%v overlaps %v %+v and %v %+v", name, w, h, k, a, other, o)
+					}
+				}
+			}
+		}
+	}
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/adrg/xdg"
-	dice "github.com/charles-m-knox/go-dicewarelib"
+	"github.com/pwiecz/go-fltk"
 )
 
 // Used for the config file directory and other things.
@@ -18,6 +22,17 @@ const APP_NAME = "go-fltk-diceware"
 // Name of the config file.
 const CONFIG_FILE = "config.json"
 
+// Extension of the backups of previous configs. The most recent one is
+// config.json.bak, and older ones are config.json.bak.1 and so on.
+const BACKUP_EXT = ".bak"
+
+// Number of previous configs that are kept as backups, rotated on every save.
+const BACKUP_COUNT = 3
+
+// How long to wait after the settings change before saving them, so that
+// repeated changes only result in a single save.
+const SAVE_DELAY = 1 * time.Second
+
 // Wraps around log.Println() as well as adding activity to the
 // activity text buffer. Always adds a newline to the activity buffer.
 func Log(v ...any) {
@@ -31,38 +46,119 @@ func Logf(format string, v ...any) {
 	log.Printf(format, v...)
 }
 
+// Loads the config from each layer in order of increasing precedence: built-in
+// defaults, system configs, the user config, GFD_* environment variables,
+// explicitly-set flags, and finally values locked by admin policies.
 func (app *App) loadConfig() {
-	var err error
-	if app.configFilePath == "" {
-		app.configFilePath, err = xdg.SearchConfigFile(path.Join(APP_NAME, CONFIG_FILE))
-		if err != nil {
-			log.Printf("failed to get xdg config dir: %v", err.Error())
-		}
+	*app.conf = defaultConfig()
+	app.systemMinEntropy = 0
+	app.systemConfigPath = ""
+	app.layers = configLayers{
+		sources:   map[string]string{},
+		user:      map[string]json.RawMessage{},
+		overrides: map[string]json.RawMessage{},
+		locked:    map[string]string{},
 	}
 
-	if app.configFilePath != "" {
-		bac, err := os.ReadFile(app.configFilePath)
-		if err != nil {
-			log.Printf("config file not readable at %v", app.configFilePath)
-		}
+	for _, k := range configKeys() {
+		app.layers.sources[k] = SOURCE_DEFAULT
+	}
 
-		err = json.Unmarshal(bac, app.conf)
-		if err != nil {
-			log.Printf("config file %v failed to parse: %v", app.configFilePath, err.Error())
+	app.applySystemConfigs()
+	app.layers.base = configValues(app.conf)
+
+	if app.configFilePath == "" {
+		dir := ""
+		if app.portableDir != "" {
+			dir = app.portableDir
+		} else if xdg.ConfigHome != "" {
+			dir = path.Join(xdg.ConfigHome, APP_NAME)
 		}
 
-		log.Printf("loaded config from %v", app.configFilePath)
-	} else {
-		if xdg.ConfigHome != "" {
-			app.configFilePath = path.Join(xdg.ConfigHome, APP_NAME, CONFIG_FILE)
-			log.Printf("using %v for config file path", app.configFilePath)
+		// use an existing config in any format, or create a JSON config
+		if dir != "" {
+			app.configFilePath = findConfigFile(dir, CONFIG_FILE)
+			if app.configFilePath == "" {
+				app.configFilePath = filepath.Join(dir, CONFIG_FILE)
+			}
 		} else {
 			log.Println("unable to automatically identify any suitable config dirs; configuration will not be saved")
 		}
 	}
+
+	if app.configFilePath != "" {
+		app.loadUserConfig()
+	}
+
+	app.applyEnv()
+	app.applyFlags()
+	app.applyPolicies()
+	app.validateConfig()
+
+	// nothing is saved in incognito mode, so the config doesn't need a copy
+	if app.layers.invalid && app.layers.preserve == "" && !app.incognito {
+		app.keepInvalidConfig()
+	}
+
+	app.loadTeamPolicy()
+	app.themes = app.findThemes()
+	app.loadedValues = configValues(app.conf)
+}
+
+// Applies the user config over the config. If it can't be parsed or has
+// invalid values, it's marked as invalid, so that loadConfig keeps a copy of
+// it as config.json.invalid before it's overwritten. If it can't be read at
+// all, it's never overwritten.
+func (app *App) loadUserConfig() {
+	values, err := app.applyConfigFile(app.configFilePath, SOURCE_USER)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("using %v for config file path", app.configFilePath)
+		return
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		app.warn("config file not readable at %v: %v; using defaults, and not saving any changes", app.configFilePath, pathErr.Err.Error())
+		app.layers.preserve = "it couldn't be read"
+		return
+	}
+
+	if err != nil {
+		app.warn("%v; using defaults", err.Error())
+		app.layers.invalid = true
+	}
+
+	if err == nil {
+		app.layers.user = values
+		log.Printf("loaded config from %v", app.configFilePath)
+	}
+
+	app.lastConfig, _ = os.ReadFile(app.configFilePath)
+}
+
+// Keeps a copy of a user config with invalid values, since they're dropped the
+// next time the config is saved. If the copy can't be made, the config is
+// never overwritten.
+func (app *App) keepInvalidConfig() {
+	b, err := os.ReadFile(app.configFilePath)
+	if err == nil {
+		err = writeFileAtomic(app.configFilePath+INVALID_EXT, b)
+	}
+
+	if err != nil {
+		app.warn("failed to keep a copy of the invalid config: %v; not saving any changes", err.Error())
+		app.layers.preserve = "it had invalid values, and a copy couldn't be kept"
+		return
+	}
+
+	app.warn("kept a copy of the invalid config as %v", app.configFilePath+INVALID_EXT)
 }
 
 func (app *App) saveConfig() error {
+	if app.incognito {
+		return nil
+	}
+
 	if app.configFilePath == "" {
 		return fmt.Errorf("received empty config filename")
 	}
@@ -71,38 +167,261 @@ func (app *App) saveConfig() error {
 		return fmt.Errorf("config was nil")
 	}
 
-	b, err := json.Marshal(app.conf)
+	if app.layers.preserve != "" {
+		return fmt.Errorf("not overwriting %v, since %v", app.configFilePath, app.layers.preserve)
+	}
+
+	old, _ := os.ReadFile(app.configFilePath)
+	b, err := encodeConfig(app.configFilePath, app.savedValues(), old)
 	if err != nil {
-		return fmt.Errorf("failed to marshal app config to yaml: %v", err.Error())
-	} else {
-		dir, _ := filepath.Split(app.configFilePath)
-		err := os.MkdirAll(dir, 0o755)
+		return fmt.Errorf("failed to marshal app config to %v: %v", configFormat(app.configFilePath), err.Error())
+	}
+
+	if bytes.Equal(old, b) {
+		app.loadedValues = configValues(app.conf)
+		return nil
+	}
+
+	dir, _ := filepath.Split(app.configFilePath)
+	err = os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create app config parent dir %v: %v", dir, err.Error())
+	}
+
+	// keep the previous configs, in case the new one turns out to be wrong
+	if old != nil {
+		err = rotateBackups(app.configFilePath+BACKUP_EXT, old)
 		if err != nil {
-			return fmt.Errorf("failed to create app config parent dir %v: %v", dir, err.Error())
+			return fmt.Errorf("failed to back up app config: %v", err.Error())
+		}
+	}
+
+	err = writeFileAtomic(app.configFilePath, b)
+	if err != nil {
+		return fmt.Errorf("failed to save app config to %v: %v", app.configFilePath, err.Error())
+	}
+
+	app.lastConfig = b
+	app.loadedValues = configValues(app.conf)
+
+	return nil
+}
+
+// Writes b as the newest of BACKUP_COUNT backups at bak, such as
+// config.json.bak, after moving each older backup one generation back, such as
+// config.json.bak to config.json.bak.1. The oldest backup is dropped.
+func rotateBackups(bak string, b []byte) error {
+	for i := BACKUP_COUNT - 1; i > 0; i-- {
+		from := bak
+		if i > 1 {
+			from = fmt.Sprintf("%v.%v", bak, i-1)
 		}
-		err = os.WriteFile(app.configFilePath, b, 0o644)
+
+		err := os.Rename(from, fmt.Sprintf("%v.%v", bak, i))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return writeFileAtomic(bak, b)
+}
+
+// Returns the file that p refers to after following any symlinks, so that
+// writing to it updates the link's target instead of replacing the link. This
+// also works for links whose target doesn't exist yet.
+func resolveSymlinks(p string) (string, error) {
+	for i := 0; i < 255; i++ {
+		r, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return r, nil
+		}
+
+		fi, lerr := os.Lstat(p)
+		if errors.Is(lerr, os.ErrNotExist) {
+			// a new file, or a link to a file in a dir that doesn't exist
+			return p, nil
+		} else if lerr != nil {
+			return "", lerr
+		}
+
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return "", err
+		}
+
+		// a dangling link, whose target is created by the write
+		target, err := os.Readlink(p)
 		if err != nil {
-			return fmt.Errorf("failed to save app config to %v: %v", app.configFilePath, err.Error())
+			return "", err
 		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(p), target)
+		}
+
+		p = target
+	}
+
+	return "", fmt.Errorf("too many levels of symbolic links at %v", p)
+}
+
+// Writes a file so that it's either completely written or not changed at all,
+// even if the app crashes or the power is lost: the data is written to a temp
+// file in the same dir, flushed to disk, and renamed over the file. The file
+// is only readable by the current user. If p is a symlink, such as a config
+// managed by a dotfile manager, its target is written instead.
+func writeFileAtomic(p string, b []byte) error {
+	p, err := resolveSymlinks(p)
+	if err != nil {
+		return err
+	}
+
+	dir, name := filepath.Split(p)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
 	}
 
+	// clean up the temp file if anything goes wrong
+	defer os.Remove(f.Name())
+
+	err = f.Chmod(0o600)
+	if err == nil {
+		_, err = f.Write(b)
+	}
+
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(f.Name(), p)
+	if err != nil {
+		return err
+	}
+
+	// make sure the rename itself is on disk
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+
+	_ = d.Sync()
+
 	return nil
 }
 
-// Initializes the diceware library with the stored word lists. Can be executed
-// repeatedly.
+// Saves the config shortly after the settings change, and checks them against
+// the team policy. Repeated changes, such as typing in an input field, only
+// result in a single save.
+func (app *App) settingsChanged() {
+	// once the callback that changed the settings has updated the log
+	fltk.Awake(app.showViolations)
+
+	if app.incognito {
+		return
+	}
+
+	app.saveMu.Lock()
+	defer app.saveMu.Unlock()
+
+	if app.saveTimer != nil {
+		app.saveTimer.Stop()
+	}
+
+	app.saveTimer = time.AfterFunc(SAVE_DELAY, func() {
+		// the config is only ever touched on the UI thread
+		fltk.Awake(func() {
+			err := app.saveConfig()
+			if err != nil {
+				log.Printf("failed to save config: %v", err.Error())
+			}
+		})
+	})
+}
+
+// Cancels any pending save, so that the config can be saved immediately.
+func (app *App) cancelPendingSave() {
+	app.saveMu.Lock()
+	defer app.saveMu.Unlock()
+
+	if app.saveTimer != nil {
+		app.saveTimer.Stop()
+		app.saveTimer = nil
+	}
+}
+
+// Initializes the word lists for the selected language. Only the selected
+// language's lists are loaded, and the extended list is only loaded when the
+// mix needs it. If the lists can't be loaded, the default language and then
+// the embedded lists are used instead; these fallbacks are only kept in
+// app.lang and app.mix, so that the config keeps the user's settings. Can be
+// executed repeatedly.
 func (app *App) initDice() {
-	simple, scount := dice.GetWords(content, "words-simple.txt")
-	app.words.Simple = &simple
-	app.words.SimpleCount = scount
-	if app.conf.Extra {
-		complex, ccount := dice.GetWords(content, "words-complex.txt")
-		app.words.Complex = &complex
-		app.words.ComplexCount = ccount
-	} else {
-		app.words.Complex = &map[int]string{} // zero out the ram usage
-		app.words.ComplexCount = 0
-	}
-	Logf("loaded %v simple words and %v complex words", app.words.SimpleCount, app.words.ComplexCount)
-	// log.Println(dice.GeneratePassword(&app.words, 3, " ", 64, 20, false))
+	if app.langs == nil {
+		app.langs = findLanguages(app.portableDir)
+	}
+
+	m, err := parseMix(app.conf.Mix)
+	if err != nil {
+		log.Printf("%v; using %v", err.Error(), MIX_SIMPLE)
+		m = Mix{Simple: 1}
+	}
+
+	var lang Language
+	var simple []string
+	candidates := []Language{findLanguage(app.langs, app.conf.Lang), findLanguage(app.langs, DEFAULT_LANG), embeddedLanguage()}
+	for i, c := range candidates {
+		if i > 0 && c.source == lang.source {
+			continue
+		}
+
+		lang = c
+		simple, err = loadWords(lang.fsys, SIMPLE_WORDS_FILE)
+		if err == nil && len(simple) > 0 {
+			break
+		}
+
+		if err == nil {
+			err = fmt.Errorf("no eligible words")
+		}
+
+		log.Printf("failed to load simple words for %v from %v: %v", lang.Name, lang.source, err)
+	}
+
+	app.lang = lang.Name
+	app.mix = m
+	app.words = WordLists{Simple: simple} // zero out the ram usage of unused lists
+	app.dict = nil
+	if m.needsComplex() && lang.hasComplex {
+		complex, err := loadWords(lang.fsys, COMPLEX_WORDS_FILE)
+		if err != nil {
+			log.Printf("failed to load complex words for %v from %v: %v", lang.Name, lang.source, err)
+		} else {
+			app.words.Complex = complex
+		}
+	} else if m.needsComplex() {
+		Logf("language %v has no extended word list; using simple words only", lang.Name)
+	}
+
+	if m.needsComplex() && len(app.words.Complex) == 0 {
+		app.mix = Mix{Simple: 1}
+	}
+
+	if m.Union && len(app.words.Complex) > 0 {
+		app.words.Union = unionWords(app.words.Simple, app.words.Complex)
+	}
+
+	Logf("loaded %v simple words and %v complex words for language %v (%v)", len(app.words.Simple), len(app.words.Complex), lang.Name, lang.source)
 }
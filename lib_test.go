@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Writing through a symlink, such as one created by a dotfile manager, must
+// update the link's target and keep the link.
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "config.json")
+	link := filepath.Join(dir, "config.json")
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(target, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filepath.Join("dotfiles", "config.json"), link); err != nil {
+		t.Skipf("symlinks aren't supported: %v", err.Error())
+	}
+
+	if err := writeFileAtomic(link, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the symlink was replaced by a regular file")
+	}
+
+	if b, _ := os.ReadFile(target); string(b) != "new" {
+		t.Errorf("target has %q, want %q", b, "new")
+	}
+
+	// a dangling link is written through as well
+	if err := os.Remove(target); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(link, []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, _ := os.ReadFile(target); string(b) != "again" {
+		t.Errorf("target has %q, want %q", b, "again")
+	}
+}
+
+func TestRotateBackups(t *testing.T) {
+	bak := filepath.Join(t.TempDir(), "config.json"+BACKUP_EXT)
+	for i := 1; i <= BACKUP_COUNT+2; i++ {
+		if err := rotateBackups(bak, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the newest backup is in .bak, and each older one is one generation back
+	newest := BACKUP_COUNT + 2
+	for i := 0; i < BACKUP_COUNT; i++ {
+		p := bak
+		if i > 0 {
+			p = fmt.Sprintf("%v.%v", bak, i)
+		}
+
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := fmt.Sprint(newest - i); string(b) != want {
+			t.Errorf("%v has %q, want %q", filepath.Base(p), b, want)
+		}
+	}
+
+	if _, err := os.Stat(fmt.Sprintf("%v.%v", bak, BACKUP_COUNT)); !os.IsNotExist(err) {
+		t.Errorf("more than %v backups were kept", BACKUP_COUNT)
+	}
+}
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"embed"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/pwiecz/go-fltk"
-
-	dice "github.com/charles-m-knox/go-dicewarelib"
 )
 
 //go:embed words-simple.txt
@@ -27,6 +32,27 @@ var version string = "dev"
 // Flag for showing the version and subsequently quitting.
 var flagVersion bool
 
+// Flag for seeding a deterministic source of randomness. Only meant for tests
+// and reproducing bug reports, so it's hidden from the usage text.
+var flagSeed string
+
+// Flag for mixing the contents of a file into the source of randomness.
+var flagEntropyFile string
+
+// Flag for storing the config next to the executable instead of in the XDG
+// config dir.
+var flagPortable bool
+
+// Flag for starting in incognito mode, which saves nothing.
+var flagIncognito bool
+
+// Deprecated flag for using the extended word list, replaced by -mix. Kept so
+// that existing scripts keep working.
+var flagExtra bool
+
+// Flags that are left out of the usage text.
+var hiddenFlags = map[string]bool{"seed": true}
+
 var (
 	// If true, the app will always be rendered in portrait mode
 	forcePortrait bool
@@ -35,9 +61,10 @@ var (
 	// app contains the shared state that is required for the entire app to
 	// function.
 	app App = App{
-		conf:  &AppConfig{},
-		ui:    &UI{},
-		words: dice.Words{},
+		conf:   &AppConfig{},
+		ui:     &UI{},
+		words:  WordLists{},
+		random: rand.Reader,
 	}
 )
 
@@ -48,17 +75,70 @@ type App struct {
 	conf *AppConfig
 	// All of the UI elements for this app are contained in the UI struct.
 	ui *UI
-	// Data is stored between runs of this application in this yml config file.
+	// Data is stored between runs of this application in this config file,
+	// which can be JSON, YAML or TOML depending on its extension.
 	configFilePath string
-	// The dictionary of diceware words, provided by the diceware lib.
-	words dice.Words
+	// The dictionary of diceware words that are eligible to be chosen.
+	words WordLists
+	// All word list languages that were found at startup.
+	langs []Language
+	// The language and mix that the loaded word lists are actually used with.
+	// These only differ from the config when its word lists couldn't be
+	// loaded, so that the fallback is never saved over the user's setting.
+	lang string
+	mix  Mix
+	// All color themes, reloaded along with the config.
+	themes []Theme
+	// If true, the desktop prefers a dark theme, which the auto theme follows.
+	desktopDark bool
+	// If true, the desktop's preference has been read, and is being followed.
+	desktopWatched bool
+	// The dictionary for the strength checker; only built while it's needed.
+	dict map[string]float64
+	// The source of randomness for generating passwords; crypto/rand.Reader
+	// unless a seed was provided for testing.
+	random io.Reader
+	// In portable mode, the dir next to the executable where the config and
+	// word lists are kept; empty otherwise.
+	portableDir string
+	// If true, nothing is written to disk for the rest of the session.
+	incognito bool
+	// Where each config value came from.
+	layers configLayers
+	// The contents of the user config file when it was last loaded or saved,
+	// so that the app's own saves don't trigger a reload.
+	lastConfig []byte
+	// The config values when the config was last loaded or saved, so that
+	// changes made in the app that haven't been saved yet survive a reload.
+	loadedValues map[string]json.RawMessage
+	// Config warnings that haven't been shown in the UI yet.
+	warnings []string
+	// Debounces saving the config after the settings change.
+	saveMu    sync.Mutex
+	saveTimer *time.Timer
+	// The minimum entropy from the system-wide config, which users can't
+	// lower, and the config file that set it.
+	systemMinEntropy float64
+	systemConfigPath string
+	// The team policy that the settings are checked against, if any.
+	teamPolicy *TeamPolicy
+	// The last password that was generated, for the NIST report.
+	lastGenerated *generatedPassword
 }
 
 type AppConfig struct {
-	// If true, the app will start in dark mode
-	DarkMode bool `json:"darkMode"`
-	// If true, uses an extended word list
-	Extra bool `json:"useExtendedWordList"`
+	// The version of the config format, used to migrate older configs
+	Version int `json:"version"`
+	// The name of the color theme, such as "light", "dark" or "high-contrast"
+	Theme string `json:"theme"`
+	// User-defined color themes, in addition to the built-in themes and theme
+	// files
+	Themes []Theme `json:"themes"`
+	// How words are drawn across the simple and extended word lists: simple,
+	// extended, union, or a simple:extended ratio such as 2:1
+	Mix string `json:"mix"`
+	// The language of the word lists to use, such as "en"
+	Lang string `json:"lang"`
 	// The maximum permissible generated output length
 	MaxLen int `json:"maxLen"`
 	// The minimum permissible generated output length
@@ -67,19 +147,89 @@ type AppConfig struct {
 	Separator string `json:"separator"`
 	// The number of words to generate
 	WordCount int `json:"wordCount"`
+	// Optional offline Have I Been Pwned data to check passwords against: a
+	// sorted hash file, a directory of range files, or a binary index
+	BreachFile string `json:"breachFile"`
+	// The attacker models that crack times are estimated for
+	Attackers []Attacker `json:"attackers"`
+	// Passwords aren't generated if the settings give less entropy than this,
+	// in bits; a system-wide config can set a higher minimum
+	MinEntropy float64 `json:"minEntropy"`
+	// Optional team password policy file that the settings are checked
+	// against, with any violations shown in the log
+	TeamPolicy string `json:"teamPolicy"`
 }
 
 func parseFlags() {
 	flag.BoolVar(&forcePortrait, "portrait", false, "force portrait orientation for the interface")
 	flag.BoolVar(&forceLandscape, "landscape", false, "force landscape orientation for the interface")
 	flag.StringVar(&app.configFilePath, "f", "", "the config file to write to, instead of the default provided by XDG config directories")
-	flag.StringVar(&app.conf.Separator, "s", " ", "the character(s) to place between each word")
-	flag.IntVar(&app.conf.MaxLen, "max", 64, "the longest permissible length of generated passwords")
-	flag.IntVar(&app.conf.MinLen, "min", 20, "the least permissible length of generated passwords")
-	flag.IntVar(&app.conf.WordCount, "wc", 3, "the number of words to generate")
-	flag.BoolVar(&app.conf.Extra, "extra", false, "if true, more complicated permutations of words will be used")
+	d := defaultConfig()
+	flag.StringVar(&flagConf.Separator, "s", d.Separator, "the character(s) to place between each word")
+	flag.IntVar(&flagConf.MaxLen, "max", d.MaxLen, "the longest permissible length of generated passwords")
+	flag.IntVar(&flagConf.MinLen, "min", d.MinLen, "the least permissible length of generated passwords")
+	flag.IntVar(&flagConf.WordCount, "wc", d.WordCount, "the number of words to generate")
+	flag.StringVar(&flagConf.Mix, "mix", d.Mix, "how words are drawn from the word lists: simple, extended, union, or a simple:extended ratio such as 2:1")
+	flag.BoolVar(&flagExtra, "extra", false, "deprecated: use -mix extended instead")
+	flag.StringVar(&flagConf.Lang, "lang", d.Lang, "the language of the word lists to use; additional languages can be placed in the XDG data dir")
+	flag.StringVar(&flagConf.BreachFile, "breach", d.BreachFile, "offline Have I Been Pwned data to check passwords against: a sorted SHA-1 hash file, a directory of range files, or a .bin index")
+	flag.Float64Var(&flagConf.MinEntropy, "min-entropy", d.MinEntropy, "refuse to generate passwords if the settings give less entropy than this, in bits")
+	flag.StringVar(&flagConf.TeamPolicy, "team-policy", d.TeamPolicy, "a team password policy file to check the settings against")
 	flag.BoolVar(&flagVersion, "v", false, "print version and exit")
+	flag.BoolVar(&flagPortable, "portable", false, fmt.Sprintf("portable mode: keep the config and word lists in %v next to the executable, and write nothing else to the host; also enabled by a %v file next to the executable", PORTABLE_DIR, PORTABLE_MARKER))
+	flag.BoolVar(&flagIncognito, "incognito", false, "incognito mode: use the saved settings, but don't save anything to disk for this session")
+	flag.StringVar(&flagImport, "import", "", "import shared settings from a settings code or an exported JSON settings file, and save them")
+	flag.StringVar(&flagEntropyFile, "entropy-file", "", "mix the contents of this file into the random source for this session, on top of the OS random source")
+	flag.StringVar(&flagSeed, "seed", "", "for testing only: generate deterministic, INSECURE passwords from this seed")
+	flag.Usage = usage
 	flag.Parse()
+	applyExtraFlag()
+}
+
+// Maps the deprecated -extra flag to the equivalent mix, unless -mix was also
+// set, which takes precedence.
+func applyExtraFlag() {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["extra"] {
+		return
+	}
+
+	log.Println("the -extra flag is deprecated; use -mix extended instead")
+	if set["mix"] {
+		return
+	}
+
+	flagConf.Mix = MIX_SIMPLE
+	if flagExtra {
+		flagConf.Mix = MIX_EXTENDED
+	}
+}
+
+// Prints the usage text, the same as the flag package's default but without
+// any hidden flags.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of %v:\n", os.Args[0])
+	flag.VisitAll(func(f *flag.Flag) {
+		if hiddenFlags[f.Name] {
+			return
+		}
+
+		name, u := flag.UnquoteUsage(f)
+		line := fmt.Sprintf("  -%v", f.Name)
+		if name != "" {
+			line = fmt.Sprintf("%v %v", line, name)
+		}
+
+		if name == "string" && f.DefValue != "" {
+			u = fmt.Sprintf("%v (default %q)", u, f.DefValue)
+		} else if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			u = fmt.Sprintf("%v (default %v)", u, f.DefValue)
+		}
+
+		fmt.Fprintf(out, "%v\n    \t%v\n", line, u)
+	})
 }
 
 func main() {
@@ -90,10 +240,61 @@ func main() {
 		os.Exit(0)
 	}
 
+	if flagSeed != "" {
+		log.Println("WARNING: using a seeded random source, generated passwords are NOT secure")
+		app.random = seededReader(flagSeed)
+	}
+
+	if flagEntropyFile != "" {
+		h := sha256.New()
+		if _, err := hashFile(h, flagEntropyFile); err != nil {
+			log.Fatalf("failed to add entropy: %v", err.Error())
+		}
+
+		if err := app.mixEntropy(h.Sum(nil)); err != nil {
+			log.Fatalf("failed to add entropy: %v", err.Error())
+		}
+	}
+
+	app.incognito = flagIncognito
+	app.portableDir = findPortableDir()
 	app.loadConfig()
+	if flagImport != "" {
+		app.importFlag()
+	}
+
 	app.initDice()
+
+	switch flag.Arg(0) {
+	case "":
+	case "selftest":
+		os.Exit(app.selftest(flag.Args()[1:]))
+	case "check":
+		os.Exit(app.check(flag.Args()[1:]))
+	case "config":
+		os.Exit(app.configCommand(flag.Args()[1:]))
+	case "gen":
+		os.Exit(app.genCommand(flag.Args()[1:]))
+	case "export":
+		os.Exit(app.exportCommand(flag.Args()[1:]))
+	case "policy":
+		os.Exit(app.policyCommand(flag.Args()[1:]))
+	case "nist-report":
+		os.Exit(app.nistCommand(flag.Args()[1:]))
+	case "breach-index":
+		os.Exit(breachIndex(flag.Args()[1:]))
+	default:
+		log.Fatalf("unknown subcommand %v", flag.Arg(0))
+	}
+
+	// Channel that receives OS signals, like ctrl+c to interrupt. Registered
+	// before the UI starts, so that an early SIGHUP doesn't kill the process.
+	var sc chan os.Signal = make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
 	app.initUI()
-	app.ui.theme(app.conf.DarkMode)
+	app.lockWidgets()
+	app.applyTheme()
 	app.ui.responsive()
 	app.ui.upsize()
 	app.setCallbacks()
@@ -102,12 +303,16 @@ func main() {
 	go fltk.Run()
 	// start with an initial password populated in the output field
 	app.gen()
+	app.showWarnings()
 
-	// Channel that receives OS signals, like ctrl+c to interrupt
-	var sc chan os.Signal = make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
-	// Block until a signal is received
-	<-sc
+	app.watchConfig()
+
+	// Block until a signal other than SIGHUP is received, which reloads the
+	// config instead
+	for sig := <-sc; sig == syscall.SIGHUP; sig = <-sc {
+		Log("received SIGHUP, reloading config")
+		fltk.Awake(app.reloadConfig)
+	}
 
 	app.gracefulExit()
 }
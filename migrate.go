@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// The current version of the config format. Bump it and add a migration
+// whenever a config key is renamed, removed or changes meaning.
+const CONFIG_VERSION = 2
+
+// Returned when a config was written by a newer version of the app.
+var errNewerConfig = errors.New("config was written by a newer version of this app")
+
+// Migrations that upgrade config values from the version at their index to the
+// next version, in order.
+var migrations = []func(values map[string]json.RawMessage) error{
+	migrateV0,
+	migrateV1,
+}
+
+// Version 0 configs had no version, and an on/off useExtendedWordList toggle
+// instead of mix.
+func migrateV0(values map[string]json.RawMessage) error {
+	raw, ok := values["useExtendedWordList"]
+	if !ok {
+		return nil
+	}
+
+	var extra bool
+	err := json.Unmarshal(raw, &extra)
+	if err != nil {
+		return fmt.Errorf("invalid useExtendedWordList: %v", err.Error())
+	}
+
+	if _, ok := values["mix"]; !ok && extra {
+		values["mix"], _ = json.Marshal(MIX_EXTENDED)
+	}
+
+	delete(values, "useExtendedWordList")
+
+	return nil
+}
+
+// Version 1 configs had an on/off darkMode toggle instead of a theme.
+func migrateV1(values map[string]json.RawMessage) error {
+	raw, ok := values["darkMode"]
+	if !ok {
+		return nil
+	}
+
+	var dark bool
+	err := json.Unmarshal(raw, &dark)
+	if err != nil {
+		return fmt.Errorf("invalid darkMode: %v", err.Error())
+	}
+
+	if _, ok := values["theme"]; !ok && dark {
+		values["theme"], _ = json.Marshal(THEME_DARK)
+	}
+
+	delete(values, "darkMode")
+
+	return nil
+}
+
+// Upgrades config values to the current version in place, and returns the
+// version that they were upgraded from.
+func migrateConfig(values map[string]json.RawMessage) (int, error) {
+	from := 0
+	if raw, ok := values["version"]; ok {
+		err := json.Unmarshal(raw, &from)
+		if err != nil {
+			return 0, fmt.Errorf("invalid version: %v", err.Error())
+		}
+	}
+
+	if from < 0 {
+		return from, fmt.Errorf("invalid version %v", from)
+	}
+
+	if from > CONFIG_VERSION {
+		return from, fmt.Errorf("%w (version %v, expected %v or older)", errNewerConfig, from, CONFIG_VERSION)
+	}
+
+	for v := from; v < CONFIG_VERSION; v++ {
+		err := migrations[v](values)
+		if err != nil {
+			return from, fmt.Errorf("failed to migrate config from version %v: %v", v, err.Error())
+		}
+	}
+
+	values["version"] = json.RawMessage(strconv.Itoa(CONFIG_VERSION))
+
+	return from, nil
+}
+
+// Keeps a copy of a config file from before it was migrated, such as
+// config.json.v0.bak, in case the migration loses anything.
+func backupConfig(p string, b []byte, version int) error {
+	bak := fmt.Sprintf("%v.v%v.bak", p, version)
+	err := os.WriteFile(bak, b, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to back up config to %v: %v", bak, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// Decodes a JSON object into config values, for test cases.
+func testValues(t *testing.T, s string) map[string]json.RawMessage {
+	t.Helper()
+
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(s), &values); err != nil {
+		t.Fatalf("invalid test config %v: %v", s, err.Error())
+	}
+
+	return values
+}
+
+func TestMigrateConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		from int
+		want string
+	}{
+		{
+			name: "v0 extended word list and dark mode",
+			in:   `{"useExtendedWordList": true, "darkMode": true, "wordCount": 4}`,
+			from: 0,
+			want: `{"mix": "extended", "theme": "dark", "wordCount": 4, "version": 2}`,
+		},
+		{
+			name: "v0 without the extended word list",
+			in:   `{"useExtendedWordList": false, "darkMode": false}`,
+			from: 0,
+			want: `{"version": 2}`,
+		},
+		{
+			name: "v0 keeps an explicit mix and theme",
+			in:   `{"useExtendedWordList": true, "mix": "2:1", "darkMode": true, "theme": "high-contrast"}`,
+			from: 0,
+			want: `{"mix": "2:1", "theme": "high-contrast", "version": 2}`,
+		},
+		{
+			name: "explicit v0",
+			in:   `{"version": 0, "useExtendedWordList": true}`,
+			from: 0,
+			want: `{"mix": "extended", "version": 2}`,
+		},
+		{
+			name: "v1 dark mode",
+			in:   `{"version": 1, "mix": "union", "darkMode": true}`,
+			from: 1,
+			want: `{"mix": "union", "theme": "dark", "version": 2}`,
+		},
+		{
+			name: "v1 only runs the migrations after v1",
+			in:   `{"version": 1, "useExtendedWordList": true}`,
+			from: 1,
+			want: `{"useExtendedWordList": true, "version": 2}`,
+		},
+		{
+			name: "current version is unchanged",
+			in:   `{"version": 2, "theme": "dark", "darkMode": true}`,
+			from: 2,
+			want: `{"version": 2, "theme": "dark", "darkMode": true}`,
+		},
+		{
+			name: "empty config",
+			in:   `{}`,
+			from: 0,
+			want: `{"version": 2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := testValues(t, tt.in)
+			from, err := migrateConfig(values)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err.Error())
+			}
+
+			if from != tt.from {
+				t.Errorf("migrated from version %v, want %v", from, tt.from)
+			}
+
+			got, _ := json.Marshal(values)
+			want, _ := json.Marshal(testValues(t, tt.want))
+			if string(got) != string(want) {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestMigrateConfigNewer(t *testing.T) {
+	values := testValues(t, `{"version": 3, "darkMode": true, "futureSetting": 1}`)
+	from, err := migrateConfig(values)
+	if !errors.Is(err, errNewerConfig) {
+		t.Fatalf("got error %v, want %v", err, errNewerConfig)
+	}
+
+	if from != 3 {
+		t.Errorf("got version %v, want 3", from)
+	}
+
+	// a newer config must not be migrated, since its keys may mean something
+	// else by now
+	got, _ := json.Marshal(values)
+	if want := `{"darkMode":true,"futureSetting":1,"version":3}`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestMigrateConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"negative version", `{"version": -1}`},
+		{"very negative version", `{"version": -9223372036854775808}`},
+		{"fractional version", `{"version": 1.5}`},
+		{"string version", `{"version": "2"}`},
+		{"invalid useExtendedWordList", `{"useExtendedWordList": "yes"}`},
+		{"invalid darkMode", `{"version": 1, "darkMode": 1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := migrateConfig(testValues(t, tt.in))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if errors.Is(err, errNewerConfig) {
+				t.Errorf("got %v, which would load the config anyway", err.Error())
+			}
+		})
+	}
+}
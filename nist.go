@@ -0,0 +1,352 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pwiecz/go-fltk"
+)
+
+// The guidance that the compliance report checks against: the memorized
+// secret requirements in section 5.1.1 of NIST SP 800-63B.
+const NIST_STANDARD = "NIST SP 800-63B"
+
+// Results of the individual checks in a compliance report. Only failures make
+// a secret non-compliant.
+const (
+	NIST_PASS        = "pass"
+	NIST_FAIL        = "fail"
+	NIST_WARN        = "warn"
+	NIST_NOT_CHECKED = "not checked"
+	// the check is about how the secret was generated, which is unknown for
+	// secrets that weren't generated by the app
+	NIST_NOT_APPLICABLE = "not applicable"
+)
+
+const (
+	// Memorized secrets SHALL be at least 8 characters long.
+	nistMinLength = 8
+	// Verifiers SHOULD permit secrets of at least 64 characters, so longer
+	// secrets may be rejected by some services.
+	nistMaxLength = 64
+	// Secrets chosen randomly by the CSP SHALL be at least 6 characters, and
+	// may be entirely numeric, so the words alone should give at least as much
+	// entropy as this many random digits.
+	nistRandomMinLength = 6
+)
+
+// Words that are specific to this app, which an attacker would try first.
+var contextWords = []string{"diceware", "fltk"}
+
+// NISTCheck is the result of checking a secret against one requirement.
+type NISTCheck struct {
+	ID          string `json:"id"`
+	Section     string `json:"section"`
+	Requirement string `json:"requirement"`
+	Status      string `json:"status"`
+	Detail      string `json:"detail"`
+}
+
+// NISTReport is the result of checking a generated secret and the settings
+// that generated it against NIST SP 800-63B. The secret itself is never part
+// of the report, so that it can be handed to auditors.
+type NISTReport struct {
+	Standard  string `json:"standard"`
+	Compliant bool   `json:"compliant"`
+	// If false, the secret isn't the last one that the app generated, such as
+	// when it was edited, so the checks of how it was generated don't apply
+	// and its entropy is unknown
+	Generated bool        `json:"generated"`
+	Length    int         `json:"length"`
+	Entropy   float64     `json:"entropy"`
+	Checks    []NISTCheck `json:"checks"`
+}
+
+// generatedPassword records the last password that the app generated, and the
+// settings that it was generated with, so that the compliance report only
+// vouches for how a secret was generated if the app really generated it.
+type generatedPassword struct {
+	// the SHA-256 hash of the password, so that it isn't kept in memory twice
+	hash   [sha256.Size]byte
+	conf   AppConfig
+	mix    Mix
+	words  WordLists
+	seeded bool
+}
+
+// Records a password that was just generated with the current settings.
+func (app *App) recordGenerated(password string) {
+	app.lastGenerated = &generatedPassword{
+		hash:   sha256.Sum256([]byte(password)),
+		conf:   *app.conf,
+		mix:    app.mix,
+		words:  app.words,
+		seeded: flagSeed != "",
+	}
+}
+
+// Returns the record of how the password was generated, or nil if it isn't
+// the last password that the app generated.
+func (app *App) generatedWith(password string) *generatedPassword {
+	g := app.lastGenerated
+	if g == nil || g.hash != sha256.Sum256([]byte(password)) {
+		return nil
+	}
+
+	return g
+}
+
+// Returns the shortest password that the settings can generate.
+func shortestPassword(c *AppConfig) int {
+	shortest := c.WordCount*minWordLength + utf8.RuneCountInString(c.Separator)*(c.WordCount-1) + suffixLength
+
+	return max(shortest, c.MinLen)
+}
+
+// Checks a password against the memorized secret requirements of NIST SP
+// 800-63B. If it's the last password that the app generated, the settings
+// that generated it are checked too; otherwise only the password itself is
+// checked, and the checks of how it was generated don't apply.
+func (app *App) nistReport(password string) NISTReport {
+	length := utf8.RuneCountInString(password)
+	g := app.generatedWith(password)
+	r := NISTReport{Standard: NIST_STANDARD, Generated: g != nil, Length: length}
+	add := func(id, section, requirement, status, detail string) {
+		r.Checks = append(r.Checks, NISTCheck{ID: id, Section: section, Requirement: requirement, Status: status, Detail: detail})
+	}
+
+	notGenerated := "the secret isn't the last password that this app generated, for example because it was edited, so how it was chosen is unknown"
+	var bits float64
+	var c *AppConfig
+	if g != nil {
+		c = &g.conf
+		bits = entropy(&g.words, g.mix, c.WordCount, c.Separator, c.MaxLen, c.MinLen)
+		r.Entropy = bits
+	}
+
+	// length
+	req := fmt.Sprintf("at least %v characters long", nistMinLength)
+	switch {
+	case length < nistMinLength:
+		add("min-length", "5.1.1.1", req, NIST_FAIL, fmt.Sprintf("the secret is only %v characters long", length))
+	case c == nil:
+		add("min-length", "5.1.1.1", req, NIST_PASS, fmt.Sprintf("the secret is %v characters long", length))
+	case shortestPassword(c) < nistMinLength:
+		add("min-length", "5.1.1.1", req, NIST_WARN, fmt.Sprintf("the secret is %v characters long, but the settings can generate secrets as short as %v characters; raise the min length or word count", length, shortestPassword(c)))
+	default:
+		add("min-length", "5.1.1.1", req, NIST_PASS, fmt.Sprintf("the secret is %v characters long, and the settings never generate fewer than %v", length, shortestPassword(c)))
+	}
+
+	req = fmt.Sprintf("accepted by verifiers, which need only permit %v characters", nistMaxLength)
+	switch {
+	case c == nil && length > nistMaxLength:
+		add("max-length", "5.1.1.2", req, NIST_WARN, fmt.Sprintf("the secret is %v characters long, which some services may reject", length))
+	case c == nil:
+		add("max-length", "5.1.1.2", req, NIST_PASS, fmt.Sprintf("the secret is %v characters long", length))
+	case length > nistMaxLength || c.MaxLen > nistMaxLength:
+		add("max-length", "5.1.1.2", req, NIST_WARN, fmt.Sprintf("the settings allow secrets of up to %v characters, which some services may reject", c.MaxLen))
+	default:
+		add("max-length", "5.1.1.2", req, NIST_PASS, fmt.Sprintf("secrets are at most %v characters long", c.MaxLen))
+	}
+
+	// randomness
+	req = "chosen with an approved random bit generator"
+	if g == nil {
+		add("random", "5.1.1.1", req, NIST_NOT_APPLICABLE, notGenerated)
+	} else if g.seeded {
+		add("random", "5.1.1.1", req, NIST_FAIL, "the secret was generated from a fixed seed with -seed, and is not secret")
+	} else {
+		add("random", "5.1.1.1", req, NIST_PASS, "words, digit and symbol are chosen uniformly with the OS random source (crypto/rand), optionally mixed with user entropy through an SP 800-90A HMAC-DRBG")
+	}
+
+	// blocklists
+	req = "not found in breach corpuses"
+	if app.conf.BreachFile == "" {
+		add("blocklist-breach", "5.1.1.2", req, NIST_NOT_CHECKED, "no breach data is configured; set it with -breach or Ctrl+B")
+	} else if n, err := app.breached(password); err != nil {
+		add("blocklist-breach", "5.1.1.2", req, NIST_FAIL, fmt.Sprintf("the breach data couldn't be searched: %v", err.Error()))
+	} else if n > 0 {
+		add("blocklist-breach", "5.1.1.2", req, NIST_FAIL, fmt.Sprintf("the secret was found in the breach data %v times", n))
+	} else {
+		add("blocklist-breach", "5.1.1.2", req, NIST_PASS, fmt.Sprintf("the secret isn't in the breach data at %v", app.conf.BreachFile))
+	}
+
+	req = "not a dictionary word, repetitive or sequential characters, or a context-specific word"
+	s := checkStrength(password, app.dictionary(), app.conf.Attackers)
+	lower := strings.ToLower(password)
+	context := ""
+	for _, w := range contextWords {
+		if strings.Contains(lower, w) {
+			context = w
+		}
+	}
+
+	switch {
+	case context != "":
+		add("blocklist-patterns", "5.1.1.2", req, NIST_FAIL, fmt.Sprintf("the secret contains the context-specific word %q", context))
+	case len(s.sequence) == 1 && s.sequence[0].pattern != "bruteforce":
+		add("blocklist-patterns", "5.1.1.2", req, NIST_FAIL, fmt.Sprintf("the whole secret is a single %v pattern", s.sequence[0].pattern))
+	default:
+		add("blocklist-patterns", "5.1.1.2", req, NIST_PASS, fmt.Sprintf("an attacker would need an estimated 10^%.1f guesses, combining %v patterns", s.GuessesLog10, len(s.sequence)))
+	}
+
+	// composition rules
+	req = "strong without depending on composition rules"
+	suffixBits := math.Log2(float64(10 * len(symbols)))
+	wordBits := bits - suffixBits
+	minBits := nistRandomMinLength * math.Log2(10)
+	if g == nil {
+		add("composition", "5.1.1.2", req, NIST_NOT_APPLICABLE, notGenerated)
+	} else if wordBits < minBits || shortestPassword(c)-suffixLength < nistMinLength {
+		add("composition", "5.1.1.2", req, NIST_FAIL, fmt.Sprintf("without the digit and symbol, the words only give %.1f bits of entropy and can be as short as %v characters", wordBits, shortestPassword(c)-suffixLength))
+	} else {
+		add("composition", "5.1.1.2", req, NIST_PASS, fmt.Sprintf("the digit and symbol add %.1f of %.1f bits; the words alone give %.1f bits", suffixBits, bits, wordBits))
+	}
+
+	r.Compliant = true
+	for _, c := range r.Checks {
+		if c.Status == NIST_FAIL {
+			r.Compliant = false
+		}
+	}
+
+	return r
+}
+
+// Formats the report as plain text, for the nist-report subcommand.
+func (r NISTReport) String() string {
+	sb := new(strings.Builder)
+	verdict := "compliant"
+	if !r.Compliant {
+		verdict = "NOT compliant"
+	}
+
+	fmt.Fprintf(sb, "%v memorized secret report: %v\n", r.Standard, verdict)
+	if r.Generated {
+		fmt.Fprintf(sb, "length: %v characters, entropy: %.1f bits\n\n", r.Length, r.Entropy)
+	} else {
+		fmt.Fprintf(sb, "length: %v characters, entropy: unknown, since the secret wasn't generated by this app\n\n", r.Length)
+	}
+	for _, c := range r.Checks {
+		fmt.Fprintf(sb, "[%v] %v (section %v)\n    %v\n", strings.ToUpper(c.Status), c.Requirement, c.Section, c.Detail)
+	}
+
+	return sb.String()
+}
+
+// Formats the report as HTML for display in a HelpView.
+func nistReportHTML(r NISTReport) string {
+	sb := new(strings.Builder)
+	if r.Compliant {
+		fmt.Fprintf(sb, "<b>Compliant with %v</b><br>", r.Standard)
+	} else {
+		fmt.Fprintf(sb, "<font color=\"red\"><b>Not compliant with %v</b></font><br>", r.Standard)
+	}
+
+	if r.Generated {
+		fmt.Fprintf(sb, "Length: %v characters, entropy: %.1f bits<br><br>", r.Length, r.Entropy)
+	} else {
+		fmt.Fprintf(sb, "Length: %v characters, entropy: unknown, since the secret wasn't generated by this app<br><br>", r.Length)
+	}
+	for _, c := range r.Checks {
+		color := "green"
+		switch c.Status {
+		case NIST_FAIL:
+			color = "red"
+		case NIST_WARN, NIST_NOT_CHECKED, NIST_NOT_APPLICABLE:
+			color = "#b07000"
+		}
+
+		fmt.Fprintf(sb, "<font color=\"%v\"><b>%v</b></font> %v (section %v)<br>%v<br><br>",
+			color, strings.ToUpper(c.Status), html.EscapeString(c.Requirement), c.Section, html.EscapeString(c.Detail))
+	}
+
+	return sb.String()
+}
+
+// Shows the compliance report for the current output, which can be saved as
+// JSON.
+func (app *App) nistWindow() {
+	password := app.ui.out.Value()
+	if password == "" {
+		fltk.MessageBox("NIST Report", "Generate a password first.")
+		return
+	}
+
+	r := app.nistReport(password)
+	// free up the memory used by the dictionary
+	app.dict = nil
+
+	win := fltk.NewWindow(460, 400, "NIST SP 800-63B Report")
+	result := fltk.NewHelpView(10, 10, 440, 340, "")
+	save := fltk.NewButton(10, 360, 120, 30, "&Save JSON...")
+	closeWin := fltk.NewButton(330, 360, 120, 30, "Close")
+	win.End()
+	win.Resizable(result)
+
+	result.SetValue(nistReportHTML(r))
+	save.SetTooltip("The report never includes the password itself.")
+
+	save.SetCallback(func() {
+		p, ok := fltk.ChooseFile("Save the report as JSON", "*.json", "nist-report.json", false)
+		if !ok || p == "" {
+			return
+		}
+
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err == nil {
+			err = os.WriteFile(p, append(b, '\n'), 0o644)
+		}
+
+		if err != nil {
+			fltk.MessageBox("Error", err.Error())
+			return
+		}
+
+		app.ui.log.SetValue(fmt.Sprintf("Saved the NIST SP 800-63B report to %v", p))
+	})
+
+	closeWin.SetCallback(func() { win.Hide() })
+
+	win.Show()
+}
+
+// Runs the nist-report subcommand, which generates a password with the current
+// settings and checks it against NIST SP 800-63B without printing it. Exits
+// non-zero if it doesn't comply. Returns the process exit code.
+func (app *App) nistCommand(args []string) int {
+	fs := flag.NewFlagSet("nist-report", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	_ = fs.Parse(args)
+
+	password, err := app.generate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate password: %v\n", err.Error())
+		return 2
+	}
+
+	r := app.nistReport(password)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode report: %v\n", err.Error())
+			return 2
+		}
+	} else {
+		fmt.Fprint(os.Stdout, r.String())
+	}
+
+	if !r.Compliant {
+		return 1
+	}
+
+	return 0
+}
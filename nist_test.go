@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+// Returns the status of each check in the report, by ID.
+func nistStatuses(r NISTReport) map[string]string {
+	statuses := map[string]string{}
+	for _, c := range r.Checks {
+		statuses[c.ID] = c.Status
+	}
+
+	return statuses
+}
+
+// Checks of how a secret was generated only apply to the last password that
+// the app generated, with the settings that it was generated with.
+func TestNISTReportGenerated(t *testing.T) {
+	a := testApp()
+	a.words = testWords
+	a.mix = Mix{Simple: 1}
+	a.random = seededReader("nist")
+	a.conf.WordCount = 4
+	a.conf.MinLen = 0
+
+	password, err := a.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := a.nistReport(password)
+	if !r.Generated || r.Entropy <= 0 {
+		t.Errorf("generated password: got generated %v and entropy %v", r.Generated, r.Entropy)
+	}
+
+	statuses := nistStatuses(r)
+	for _, id := range []string{"random", "composition"} {
+		if statuses[id] == NIST_NOT_APPLICABLE {
+			t.Errorf("generated password: %v was %v", id, statuses[id])
+		}
+	}
+
+	// changing the settings afterwards doesn't change the report
+	a.conf.WordCount = 1
+	if got := a.nistReport(password); got.Entropy != r.Entropy {
+		t.Errorf("entropy changed from %v to %v with the settings", r.Entropy, got.Entropy)
+	}
+
+	edited := password + "x"
+	r = a.nistReport(edited)
+	if r.Generated || r.Entropy != 0 {
+		t.Errorf("edited password: got generated %v and entropy %v", r.Generated, r.Entropy)
+	}
+
+	statuses = nistStatuses(r)
+	for _, id := range []string{"random", "composition"} {
+		if statuses[id] != NIST_NOT_APPLICABLE {
+			t.Errorf("edited password: %v was %v, want %v", id, statuses[id], NIST_NOT_APPLICABLE)
+		}
+	}
+
+	if statuses["min-length"] != NIST_PASS {
+		t.Errorf("edited password: min-length was %v, want %v", statuses["min-length"], NIST_PASS)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+
+	"github.com/adrg/xdg"
+)
+
+// Name of the admin policy file, which is only read from the system config
+// dirs, such as /etc/xdg/go-fltk-diceware/policy.json.
+const POLICY_FILE = "policy.json"
+
+// Source of config values that are locked by a policy file.
+const SOURCE_POLICY = "locked by policy"
+
+// AdminPolicy is a system-wide policy file that locks config values on managed
+// machines. Locked values override every other config layer, including flags,
+// and can't be changed in the app.
+type AdminPolicy struct {
+	// Config keys and the values that they're locked to, such as
+	// {"wordCount": 6, "mix": "extended", "minEntropy": 70}
+	Locked map[string]json.RawMessage `json:"locked"`
+}
+
+// Applies the policy files from the XDG config dirs over the config. Dirs
+// earlier in XDG_CONFIG_DIRS take precedence.
+func (app *App) applyPolicies() {
+	for i := len(xdg.ConfigDirs) - 1; i >= 0; i-- {
+		p := path.Join(xdg.ConfigDirs[i], APP_NAME, POLICY_FILE)
+		b, err := os.ReadFile(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			log.Printf("policy file not readable at %v: %v", p, err.Error())
+			continue
+		}
+
+		var policy AdminPolicy
+		err = json.Unmarshal(b, &policy)
+		if err != nil {
+			log.Printf("policy file %v failed to parse: %v", p, err.Error())
+			continue
+		}
+
+		// policies have no version, so every migration is applied; each one
+		// only touches the keys that it renames
+		for _, migrate := range migrations {
+			if err := migrate(policy.Locked); err != nil {
+				log.Printf("policy file %v: %v", p, err.Error())
+			}
+		}
+
+		for _, name := range sortedKeys(policy.Locked) {
+			// unknown keys would otherwise be silently ignored while looking
+			// locked to the admin
+			k, ok := canonicalKey(name)
+			if !ok {
+				app.warn("ignoring unknown setting %q in policy file %v", name, p)
+				continue
+			}
+
+			previous := app.layers.sources[k]
+			err := app.applyValue(k, policy.Locked[name], fmt.Sprintf("%v %v", SOURCE_POLICY, p))
+			if err != nil {
+				app.warn("ignoring invalid value in policy file %v: %v", p, err.Error())
+				continue
+			}
+
+			if _, ok := app.layers.overrides[k]; ok {
+				log.Printf("ignoring %v, since %v is locked by %v", previous, k, p)
+			}
+
+			app.layers.overrides[k] = configValues(app.conf)[k]
+			app.layers.locked[k] = p
+		}
+
+		log.Printf("loaded policy from %v", p)
+	}
+}
+
+// Returns an error if the config key is locked by a policy file.
+func (app *App) checkLocked(key string) error {
+	if p, ok := app.layers.locked[key]; ok {
+		return fmt.Errorf("%v is locked by your administrator in %v", key, p)
+	}
+
+	return nil
+}
+
+// Deactivates the widgets for locked config values, with a tooltip that
+// explains why, and reactivates the rest.
+func (app *App) lockWidgets() {
+	for k, w := range app.ui.settingWidgets() {
+		if p, ok := app.layers.locked[k]; ok {
+			w.Deactivate()
+			w.SetTooltip(fmt.Sprintf("Locked by your administrator in %v, and can't be changed.", p))
+		} else {
+			w.Activate()
+			w.SetTooltip(settingTooltips[k])
+		}
+	}
+}
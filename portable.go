@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Name of the marker file that enables portable mode when it's placed next to
+// the executable.
+const PORTABLE_MARKER = "go-fltk-diceware.portable"
+
+// Name of the dir next to the executable where everything is stored in
+// portable mode.
+const PORTABLE_DIR = "go-fltk-diceware-data"
+
+// Returns the dir next to the executable where the config and word lists are
+// kept in portable mode, or "" if portable mode is off. Portable mode is on if
+// the -portable flag is set, or if the marker file is next to the executable.
+func findPortableDir() string {
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+
+	if err != nil {
+		if flagPortable {
+			log.Fatalf("failed to find the executable for portable mode: %v", err.Error())
+		}
+
+		return ""
+	}
+
+	dir := filepath.Dir(exe)
+	if !flagPortable {
+		if _, err := os.Stat(filepath.Join(dir, PORTABLE_MARKER)); err != nil {
+			return ""
+		}
+	}
+
+	p := filepath.Join(dir, PORTABLE_DIR)
+	log.Printf("portable mode: using %v instead of the XDG config dir", p)
+
+	return p
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pwiecz/go-fltk"
+)
+
+// Source of config values that were changed in the app and re-applied over a
+// reloaded config before they could be saved.
+const SOURCE_UNSAVED = "unsaved change in the app"
+
+// How long to wait for a burst of file events to settle before reloading the
+// config, since editors and dotfile managers often write a file in several
+// steps.
+const RELOAD_DELAY = 200 * time.Millisecond
+
+// Watches the user config file, and reloads the config whenever it changes.
+// The dir is watched rather than the file, so that files that are replaced
+// rather than written in place (including by saveConfig) are picked up.
+func (app *App) watchConfig() {
+	if app.configFilePath == "" {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("failed to watch config file: %v", err.Error())
+		return
+	}
+
+	p := filepath.Clean(app.configFilePath)
+	dir := filepath.Dir(p)
+	// the dir doesn't exist until the config is first saved, which never
+	// happens in incognito mode
+	if !app.incognito {
+		err = os.MkdirAll(dir, 0o700)
+	}
+
+	if err == nil {
+		err = watcher.Add(dir)
+	}
+
+	if err != nil {
+		log.Printf("failed to watch config dir %v: %v", dir, err.Error())
+		watcher.Close()
+		return
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if filepath.Clean(e.Name) != p || !e.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+
+				if timer != nil {
+					timer.Stop()
+				}
+
+				timer = time.AfterFunc(RELOAD_DELAY, func() { fltk.Awake(app.reloadIfChanged) })
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				log.Printf("config watcher error: %v", err.Error())
+			}
+		}
+	}()
+}
+
+// Reloads the config if the user config file is different from what was last
+// loaded or saved, which skips the app's own saves.
+func (app *App) reloadIfChanged() {
+	b, err := os.ReadFile(app.configFilePath)
+	if err != nil || bytes.Equal(b, app.lastConfig) {
+		return
+	}
+
+	app.reloadConfig()
+}
+
+// Reloads every config layer, and applies the new config to the widgets and
+// the word lists. Settings that were changed in the app but not saved yet are
+// applied over the reloaded config, unless they've been locked since, and are
+// saved shortly after. Must be called on the UI thread.
+func (app *App) reloadConfig() {
+	old := *app.conf
+	unsaved := app.unsavedValues()
+	app.cancelPendingSave()
+	app.loadConfig()
+
+	reapplied := false
+	for _, k := range sortedKeys(unsaved) {
+		if app.checkLocked(k) != nil {
+			continue
+		}
+
+		err := app.applyValue(k, unsaved[k], SOURCE_UNSAVED)
+		if err != nil {
+			app.warn("dropping unsaved change to %v: %v", k, err.Error())
+			continue
+		}
+
+		reapplied = true
+	}
+
+	if reapplied {
+		app.validateConfig()
+		app.settingsChanged()
+	}
+
+	if old.Lang != app.conf.Lang || old.Mix != app.conf.Mix {
+		app.initDice()
+	}
+
+	// theme files may have changed too
+	app.themeCB()
+	app.applyTheme()
+
+	app.syncWidgets()
+	app.lockWidgets()
+	app.ui.log.SetValue(fmt.Sprintf("Reloaded config from %v", app.configFilePath))
+	app.showWarnings()
+	app.showViolations()
+}
+
+// Returns the config values that were changed in the app since the config was
+// last loaded or saved.
+func (app *App) unsavedValues() map[string]json.RawMessage {
+	unsaved := map[string]json.RawMessage{}
+	values := configValues(app.conf)
+	for _, k := range configKeys() {
+		if !bytes.Equal(values[k], app.loadedValues[k]) {
+			unsaved[k] = values[k]
+		}
+	}
+
+	return unsaved
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Default number of passphrases that are generated by the self-test.
+const SELFTEST_SAMPLES = 100000
+
+// Default significance level; a test fails if the probability of seeing its
+// result from an unbiased generator is lower than this.
+const SELFTEST_ALPHA = 0.001
+
+// Chi-squared tests are only accurate when every bin is expected to receive at
+// least this many samples, so smaller bins are merged together.
+const minExpectedPerBin = 5
+
+// The outcome of a single self-test.
+type testResult struct {
+	name string
+	// chi-squared statistic, degrees of freedom and p-value; unused for checks
+	// that aren't statistical
+	chi2 float64
+	df   int
+	p    float64
+	pass bool
+	// explains the result of non-statistical checks
+	note string
+}
+
+// Runs the selftest subcommand, which generates a large number of passphrases
+// with the current settings and tests that every random choice is unbiased.
+// Returns the process exit code: 0 if every test passed, 1 if any failed, and
+// 2 for invalid arguments.
+func (app *App) selftest(args []string) int {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	samples := fs.Int("n", SELFTEST_SAMPLES, "the number of passphrases to generate")
+	alpha := fs.Float64("alpha", SELFTEST_ALPHA, "the significance level below which a test fails")
+	_ = fs.Parse(args)
+
+	if *samples < 1 {
+		fmt.Fprintf(fs.Output(), "-n must be at least 1, not %v\n", *samples)
+		fs.Usage()
+		return 2
+	}
+
+	out := os.Stdout
+
+	m := app.mix
+	pools, err := checkedPools(&app.words, m, app.conf.WordCount)
+	if err != nil {
+		fmt.Fprintf(out, "FAIL: %v\n", err.Error())
+		return 1
+	}
+
+	fmt.Fprintf(out, "self-test: %v passphrases, language %v, mix %v, %v words, separator %q, length %v-%v, alpha %v\n",
+		*samples, app.lang, m, app.conf.WordCount, app.conf.Separator, app.conf.MinLen, app.conf.MaxLen, *alpha)
+	fmt.Fprintf(out, "the separator is fixed by the settings rather than chosen at random, so there is no separator choice to test\n\n")
+
+	results := []testResult{}
+	for _, n := range []int{2, 3, 7, 10, 1000} {
+		results = append(results, testRandInt(app.random, n, *samples, *alpha))
+	}
+
+	genResults, err := testGenerator(app.random, pools, app.conf.Separator, app.conf.MaxLen, app.conf.MinLen, *samples, *alpha)
+	if err != nil {
+		fmt.Fprintf(out, "FAIL: %v\n", err.Error())
+		return 1
+	}
+
+	results = append(results, genResults...)
+
+	return printResults(out, results)
+}
+
+// Prints the report and returns 0 if every test passed, 1 otherwise.
+func printResults(out io.Writer, results []testResult) int {
+	failed := 0
+	for _, r := range results {
+		status := "PASS"
+		if !r.pass {
+			status = "FAIL"
+			failed++
+		}
+
+		if r.note != "" {
+			fmt.Fprintf(out, "%v  %-36v %v\n", status, r.name, r.note)
+			continue
+		}
+
+		fmt.Fprintf(out, "%v  %-36v chi2=%-12.2f df=%-6v p=%.4f\n", status, r.name, r.chi2, r.df, r.p)
+	}
+
+	fmt.Fprintf(out, "\n%v of %v tests passed\n", len(results)-failed, len(results))
+	if failed > 0 {
+		fmt.Fprintln(out, "a single failure can happen by chance; re-run the self-test, and report the generator as biased if it keeps failing")
+		return 1
+	}
+
+	return 0
+}
+
+// Tests that randInt picks every number from 0 to n-1 equally often, which
+// catches modulo bias.
+func testRandInt(r io.Reader, n int, samples int, alpha float64) testResult {
+	name := fmt.Sprintf("random numbers 0-%v", n-1)
+	observed := make([]int64, n)
+	for i := 0; i < samples; i++ {
+		k, err := randInt(r, n)
+		if err != nil {
+			return testResult{name: name, note: err.Error()}
+		}
+
+		observed[k]++
+	}
+
+	expected := make([]float64, n)
+	for i := range expected {
+		expected[i] = 1 / float64(n)
+	}
+
+	return chiSquaredTest(name, observed, expected, samples, alpha)
+}
+
+// Generates passphrases from the pools and tests the word choices for each
+// word position (both by position in the word list and by word length, which
+// catches bias from discarding passwords of the wrong length), the digit and
+// symbol choices, and the formatting of the output.
+func testGenerator(r io.Reader, pools [][]string, s string, maxLen int, minLen int, samples int, alpha float64) ([]testResult, error) {
+	wordCounts := make([][]int64, len(pools))
+	for i, pool := range pools {
+		wordCounts[i] = make([]int64, len(pool))
+	}
+
+	digits := make([]int64, 10)
+	syms := make([]int64, len(symbols))
+	malformed := 0
+	for i := 0; i < samples; i++ {
+		d, err := drawPassword(r, pools, s, maxLen, minLen)
+		if err != nil {
+			return nil, err
+		}
+
+		for j, k := range d.Words {
+			wordCounts[j][k]++
+		}
+
+		digits[d.Digit]++
+		syms[d.Symbol]++
+
+		p := d.format(pools, s)
+		l := utf8.RuneCountInString(p)
+		first, _ := utf8.DecodeRuneInString(p)
+		if l < minLen || l > maxLen || unicode.ToUpper(first) != first {
+			malformed++
+		}
+	}
+
+	results := []testResult{}
+	for i, pool := range pools {
+		probs, err := wordProbabilities(pools, i, s, maxLen, minLen)
+		if err != nil {
+			return nil, err
+		}
+
+		// group the words into contiguous bins by their position in the word
+		// list, so that large lists still have enough samples per bin
+		bins := min(len(pool), max(2, samples/(minExpectedPerBin*10)))
+		observed := make([]int64, bins)
+		expected := make([]float64, bins)
+		for k := range pool {
+			b := k * bins / len(pool)
+			observed[b] += wordCounts[i][k]
+			expected[b] += probs[k]
+		}
+
+		results = append(results, chiSquaredTest(fmt.Sprintf("word %v choice (%v bins)", i+1, bins), observed, expected, samples, alpha))
+
+		// group the same counts by word length
+		lengths := map[int]int{}
+		observed = []int64{}
+		expected = []float64{}
+		for k, w := range pool {
+			l := utf8.RuneCountInString(w)
+			b, ok := lengths[l]
+			if !ok {
+				b = len(observed)
+				lengths[l] = b
+				observed = append(observed, 0)
+				expected = append(expected, 0)
+			}
+
+			observed[b] += wordCounts[i][k]
+			expected[b] += probs[k]
+		}
+
+		results = append(results, chiSquaredTest(fmt.Sprintf("word %v length", i+1), observed, expected, samples, alpha))
+	}
+
+	uniform := func(n int) []float64 {
+		e := make([]float64, n)
+		for i := range e {
+			e[i] = 1 / float64(n)
+		}
+
+		return e
+	}
+
+	results = append(results,
+		chiSquaredTest("digit choice", digits, uniform(len(digits)), samples, alpha),
+		chiSquaredTest("symbol choice", syms, uniform(len(syms)), samples, alpha),
+		testResult{
+			name: "formatting and capitalization",
+			pass: malformed == 0,
+			note: fmt.Sprintf("%v of %v passphrases had the wrong length or a lower-case first letter", malformed, samples),
+		},
+	)
+
+	return results, nil
+}
+
+// Returns the exact probability of each word in pools[i] being chosen for
+// word position i. Words are chosen uniformly, but since passwords of the
+// wrong length are discarded, words whose length makes the other positions
+// harder to fit are less likely.
+func wordProbabilities(pools [][]string, i int, s string, maxLen int, minLen int) ([]float64, error) {
+	others := make([][]string, 0, len(pools)-1)
+	others = append(others, pools[:i]...)
+	others = append(others, pools[i+1:]...)
+	counts := lengthCounts(others)
+	fixed := utf8.RuneCountInString(s)*(len(pools)-1) + suffixLength
+
+	// the number of accepted combinations for a word of each length
+	weights := map[int]*big.Int{}
+	total := new(big.Int)
+	for l, wc := range lengthHistogram(pools[i]) {
+		w := new(big.Int)
+		for ol, c := range counts {
+			if l+ol+fixed <= maxLen && l+ol+fixed >= minLen {
+				w.Add(w, c)
+			}
+		}
+
+		weights[l] = w
+		total.Add(total, new(big.Int).Mul(w, big.NewInt(wc)))
+	}
+
+	if total.Sign() <= 0 {
+		return nil, fmt.Errorf("no passphrase can satisfy the min/max length")
+	}
+
+	probs := map[int]float64{}
+	for l, w := range weights {
+		probs[l], _ = new(big.Float).Quo(new(big.Float).SetInt(w), new(big.Float).SetInt(total)).Float64()
+	}
+
+	result := make([]float64, len(pools[i]))
+	for k, w := range pools[i] {
+		result[k] = probs[utf8.RuneCountInString(w)]
+	}
+
+	return result, nil
+}
+
+// Runs Pearson's chi-squared test of the observed counts against the expected
+// probabilities. Bins that are expected to receive too few samples are merged
+// with their neighbors first.
+func chiSquaredTest(name string, observed []int64, expected []float64, samples int, alpha float64) testResult {
+	mergedO := []float64{}
+	mergedE := []float64{}
+	var o, e float64
+	for i := range observed {
+		// an outcome that should be impossible has occurred
+		if expected[i] == 0 && observed[i] > 0 {
+			return testResult{name: name, chi2: math.Inf(1), df: len(observed) - 1, p: 0, pass: false}
+		}
+
+		o += float64(observed[i])
+		e += expected[i] * float64(samples)
+		if e < minExpectedPerBin {
+			continue
+		}
+
+		mergedO = append(mergedO, o)
+		mergedE = append(mergedE, e)
+		o, e = 0, 0
+	}
+
+	// whatever is left over is too small for its own bin
+	if e > 0 && len(mergedE) > 0 {
+		mergedO[len(mergedO)-1] += o
+		mergedE[len(mergedE)-1] += e
+	}
+
+	df := len(mergedE) - 1
+	if df < 1 {
+		return testResult{name: name, pass: true, note: "not enough bins to test"}
+	}
+
+	chi2 := 0.0
+	for i := range mergedE {
+		chi2 += (mergedO[i] - mergedE[i]) * (mergedO[i] - mergedE[i]) / mergedE[i]
+	}
+
+	p := gammaQ(float64(df)/2, chi2/2)
+
+	return testResult{name: name, chi2: chi2, df: df, p: p, pass: p >= alpha}
+}
+
+// Returns the regularized upper incomplete gamma function Q(a, x), which gives
+// the p-value of a chi-squared statistic x*2 with a*2 degrees of freedom.
+func gammaQ(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+
+	lg, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lg)
+
+	// the series converges quickly for small x
+	if x < a+1 {
+		sum := 1 / a
+		del := sum
+		for n := 1; n < 10000; n++ {
+			del *= x / (a + float64(n))
+			sum += del
+			if math.Abs(del) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+
+		return 1 - sum*prefix
+	}
+
+	// otherwise use a continued fraction, evaluated with Lentz's method
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 10000; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-15 {
+			break
+		}
+	}
+
+	return prefix * h
+}
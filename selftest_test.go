@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+// Known p-values from chi-squared distribution tables.
+func TestGammaQ(t *testing.T) {
+	tests := []struct {
+		chi2 float64
+		df   int
+		want float64
+	}{
+		{3.841, 1, 0.05},
+		{6.635, 1, 0.01},
+		{10.828, 1, 0.001},
+		{5.991, 2, 0.05},
+		{7.815, 3, 0.05},
+		{18.307, 10, 0.05},
+		{23.209, 10, 0.01},
+		{9.342, 10, 0.5},
+		{124.342, 100, 0.05},
+		{0, 5, 1},
+	}
+
+	for _, tt := range tests {
+		if got := gammaQ(float64(tt.df)/2, tt.chi2/2); math.Abs(got-tt.want) > 1e-3 {
+			t.Errorf("chi2=%v df=%v: got p=%v, want %v", tt.chi2, tt.df, got, tt.want)
+		}
+	}
+
+	// with 2 degrees of freedom, the p-value is exactly e^(-chi2/2)
+	for _, chi2 := range []float64{0.5, 1, 4, 20, 100} {
+		if got, want := gammaQ(1, chi2/2), math.Exp(-chi2/2); math.Abs(got-want) > 1e-12 {
+			t.Errorf("chi2=%v df=2: got p=%v, want %v", chi2, got, want)
+		}
+	}
+}
+
+func TestChiSquaredTest(t *testing.T) {
+	tests := []struct {
+		name     string
+		observed []int64
+		expected []float64
+		chi2     float64
+		df       int
+		pass     bool
+	}{
+		{"exact", []int64{250, 250, 250, 250}, []float64{0.25, 0.25, 0.25, 0.25}, 0, 3, true},
+		{"close", []int64{260, 240, 255, 245}, []float64{0.25, 0.25, 0.25, 0.25}, 1, 3, true},
+		{"biased", []int64{400, 200, 200, 200}, []float64{0.25, 0.25, 0.25, 0.25}, 120, 3, false},
+		{"non-uniform expectation", []int64{500, 250, 250}, []float64{0.5, 0.25, 0.25}, 0, 2, true},
+		{"small bins are merged", []int64{498, 500, 1, 1}, []float64{0.499, 0.499, 0.001, 0.001}, 1.0/499 + 1.0/501, 1, true},
+		{"impossible outcome", []int64{500, 499, 1}, []float64{0.5, 0.5, 0}, math.Inf(1), 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chiSquaredTest(tt.name, tt.observed, tt.expected, 1000, SELFTEST_ALPHA)
+			if math.Abs(got.chi2-tt.chi2) > 1e-9 && !(math.IsInf(tt.chi2, 1) && math.IsInf(got.chi2, 1)) {
+				t.Errorf("got chi2 %v, want %v", got.chi2, tt.chi2)
+			}
+
+			if got.df != tt.df || got.pass != tt.pass {
+				t.Errorf("got df %v and pass %v, want df %v and pass %v", got.df, got.pass, tt.df, tt.pass)
+			}
+		})
+	}
+}
+
+// Counts numbers picked with the classic modulo bias: taking a random byte
+// modulo 100 makes 0-55 half again as likely as 56-99.
+func moduloCounts(r io.Reader, n, samples int) []int64 {
+	observed := make([]int64, n)
+	b := make([]byte, samples)
+	_, _ = io.ReadFull(r, b)
+	for _, c := range b {
+		observed[int(c)%n]++
+	}
+
+	return observed
+}
+
+func TestSelftestDetectsBias(t *testing.T) {
+	uniform := make([]float64, 100)
+	for i := range uniform {
+		uniform[i] = 0.01
+	}
+
+	samples := 100000
+	observed := moduloCounts(seededReader("bias"), 100, samples)
+	if r := chiSquaredTest("modulo bias", observed, uniform, samples, SELFTEST_ALPHA); r.pass {
+		t.Errorf("a modulo-biased source passed: %+v", r)
+	}
+
+	// a stuck source always picks the same number
+	if r := testRandInt(bytes.NewReader(make([]byte, 1<<20)), 10, 10000, SELFTEST_ALPHA); r.pass {
+		t.Errorf("a source of zeros passed: %+v", r)
+	}
+
+	// and an unbiased source passes
+	if r := testRandInt(seededReader("fair"), 10, samples, SELFTEST_ALPHA); !r.pass {
+		t.Errorf("an unbiased source failed: %+v", r)
+	}
+}
+
+func TestTestGenerator(t *testing.T) {
+	m, err := parseMix("2:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pools, err := checkedPools(&testWords, m, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := testGenerator(seededReader("selftest"), pools, "-", 64, 20, 20000, SELFTEST_ALPHA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range results {
+		if !r.pass {
+			t.Errorf("%v failed with an unbiased source: %+v", r.name, r)
+		}
+	}
+}
+
+func TestSelftestRejectsNoSamples(t *testing.T) {
+	for _, n := range []string{"0", "-5"} {
+		if code := testApp().selftest([]string{"-n", n}); code != 2 {
+			t.Errorf("-n %v exited with %v, want 2", n, code)
+		}
+	}
+}
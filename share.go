@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/pwiecz/go-fltk"
+)
+
+// Version of the shareable settings code and file format. Codes look like
+// gfd1.<base64url JSON>.<checksum>, so that they survive being pasted into
+// chat, and the version is part of the prefix.
+const (
+	SETTINGS_CODE_PREFIX  = "gfd"
+	SETTINGS_CODE_VERSION = 1
+)
+
+// Value of the "format" field of exported settings files.
+const SETTINGS_FILE_FORMAT = "go-fltk-diceware-settings"
+
+// Number of bytes of the SHA-256 hash of a settings code that are kept as its
+// checksum, which catches typos and truncated pastes.
+const settingsChecksumLength = 4
+
+// Source of config values that were imported from a settings code or file.
+const SOURCE_IMPORT = "imported settings"
+
+// The config keys that are shared, and their short names in settings codes.
+// Only the generation settings are shared; never passwords, file paths such as
+// breachFile, or the appearance.
+var sharedSettings = []struct{ key, short string }{
+	{"wordCount", "w"},
+	{"mix", "m"},
+	{"lang", "l"},
+	{"separator", "s"},
+	{"minLen", "n"},
+	{"maxLen", "x"},
+	{"minEntropy", "e"},
+}
+
+// Flag for importing a settings code, or a settings file exported as JSON.
+var flagImport string
+
+// settingsFile is the JSON file format for exported settings.
+type settingsFile struct {
+	Format   string                     `json:"format"`
+	Version  int                        `json:"version"`
+	Settings map[string]json.RawMessage `json:"settings"`
+}
+
+// Returns the current values of the shared settings, by config key.
+func (app *App) sharedValues() map[string]json.RawMessage {
+	values := configValues(app.conf)
+	shared := map[string]json.RawMessage{}
+	for _, s := range sharedSettings {
+		shared[s.key] = values[s.key]
+	}
+
+	return shared
+}
+
+// Returns the checksum of the prefix and payload of a settings code.
+func settingsChecksum(prefix, payload string) string {
+	sum := sha256.Sum256([]byte(prefix + "." + payload))
+	return base64.RawURLEncoding.EncodeToString(sum[:settingsChecksumLength])
+}
+
+// Returns the shareable settings code for the current settings.
+func (app *App) settingsCode() (string, error) {
+	values := app.sharedValues()
+	short := map[string]json.RawMessage{}
+	for _, s := range sharedSettings {
+		short[s.short] = values[s.key]
+	}
+
+	b, err := json.Marshal(short)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode settings: %v", err.Error())
+	}
+
+	prefix := fmt.Sprintf("%v%v", SETTINGS_CODE_PREFIX, SETTINGS_CODE_VERSION)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+
+	return fmt.Sprintf("%v.%v.%v", prefix, payload, settingsChecksum(prefix, payload)), nil
+}
+
+// Returns the current settings as an indented JSON settings file.
+func (app *App) settingsJSON() ([]byte, error) {
+	b, err := json.MarshalIndent(settingsFile{
+		Format:   SETTINGS_FILE_FORMAT,
+		Version:  SETTINGS_CODE_VERSION,
+		Settings: app.sharedValues(),
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode settings: %v", err.Error())
+	}
+
+	return append(b, '\n'), nil
+}
+
+// Parses a settings code or the contents of a settings file into settings
+// values, by config key. Unknown settings are rejected rather than ignored, so
+// that nothing is silently lost.
+func parseSettings(s string) (map[string]json.RawMessage, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") {
+		return parseSettingsFile([]byte(s))
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], SETTINGS_CODE_PREFIX) {
+		return nil, fmt.Errorf("not a settings code; codes look like %v%v.<settings>.<checksum>", SETTINGS_CODE_PREFIX, SETTINGS_CODE_VERSION)
+	}
+
+	v, err := strconv.Atoi(strings.TrimPrefix(parts[0], SETTINGS_CODE_PREFIX))
+	if err != nil || v < 1 || parts[0] != fmt.Sprintf("%v%v", SETTINGS_CODE_PREFIX, v) {
+		return nil, fmt.Errorf("invalid settings code version %q", parts[0])
+	} else if v > SETTINGS_CODE_VERSION {
+		return nil, fmt.Errorf("the settings code is from a newer version of this app")
+	}
+
+	if parts[2] != settingsChecksum(parts[0], parts[1]) {
+		return nil, fmt.Errorf("the settings code is damaged or incomplete; check that it was copied in full")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode settings code: %v", err.Error())
+	}
+
+	short := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &short); err != nil {
+		return nil, fmt.Errorf("failed to decode settings code: %v", err.Error())
+	}
+
+	values := map[string]json.RawMessage{}
+	for _, s := range sharedSettings {
+		if raw, ok := short[s.short]; ok {
+			values[s.key] = raw
+			delete(short, s.short)
+		}
+	}
+
+	if len(short) > 0 {
+		return nil, fmt.Errorf("the settings code has unknown settings %v", strings.Join(sortedKeys(short), ", "))
+	}
+
+	return values, nil
+}
+
+// Parses the contents of a settings file that was exported as JSON.
+func parseSettingsFile(b []byte) (map[string]json.RawMessage, error) {
+	var f settingsFile
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&f); err != nil {
+		return nil, fmt.Errorf("settings file failed to parse: %v", err.Error())
+	}
+
+	if f.Format != SETTINGS_FILE_FORMAT {
+		return nil, fmt.Errorf("not a settings file; its format must be %q", SETTINGS_FILE_FORMAT)
+	} else if f.Version < 1 {
+		return nil, fmt.Errorf("invalid settings file version %v", f.Version)
+	} else if f.Version > SETTINGS_CODE_VERSION {
+		return nil, fmt.Errorf("the settings file is from a newer version of this app")
+	}
+
+	unknown := []string{}
+	for _, k := range sortedKeys(f.Settings) {
+		if !isSharedSetting(k) {
+			unknown = append(unknown, k)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("the settings file has unknown settings %v", strings.Join(unknown, ", "))
+	}
+
+	return f.Settings, nil
+}
+
+// Returns true if the config key is one of the shared settings.
+func isSharedSetting(key string) bool {
+	for _, s := range sharedSettings {
+		if s.key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads a settings code, or a settings file if s is the path to one.
+func readSettings(s string) (map[string]json.RawMessage, error) {
+	if _, err := os.Stat(s); err == nil {
+		b, err := os.ReadFile(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read settings file %v: %v", s, err.Error())
+		}
+
+		return parseSettingsFile(b)
+	}
+
+	return parseSettings(s)
+}
+
+// Validates imported settings and applies them over the config. Either every
+// setting is applied or, if any of them is invalid, none are. Settings that are
+// locked by a policy are skipped, and returned.
+func (app *App) importSettings(values map[string]json.RawMessage) ([]string, error) {
+	if app.langs == nil {
+		app.langs = findLanguages(app.portableDir)
+	}
+
+	c := *app.conf
+	current := configValues(app.conf)
+	skipped := []string{}
+	imported := []string{}
+	for _, k := range sortedKeys(values) {
+		raw := normalizedSetting(k, values[k])
+		if app.checkLocked(k) != nil {
+			if !bytes.Equal(raw, current[k]) {
+				skipped = append(skipped, k)
+			}
+
+			continue
+		}
+
+		if err := setConfigValue(&c, k, raw); err != nil {
+			return nil, err
+		}
+
+		if err := validateField(&c, k); err != nil {
+			return nil, err
+		}
+
+		imported = append(imported, k)
+	}
+
+	if c.MinLen > c.MaxLen {
+		return nil, fmt.Errorf("minLen %v is greater than maxLen %v", c.MinLen, c.MaxLen)
+	}
+
+	if findLanguage(app.langs, c.Lang).Name != c.Lang {
+		return nil, fmt.Errorf("word lists for language %v aren't installed", c.Lang)
+	}
+
+	*app.conf = c
+	for _, k := range imported {
+		app.layers.sources[k] = SOURCE_IMPORT
+	}
+
+	return skipped, nil
+}
+
+// Returns the compact form of a JSON value, so that it compares equal to the
+// values from configValues.
+func normalizedRaw(raw json.RawMessage) json.RawMessage {
+	buf := new(bytes.Buffer)
+	if err := json.Compact(buf, raw); err != nil {
+		return raw
+	}
+
+	return buf.Bytes()
+}
+
+// Returns a setting the way it's stored, so that a mix such as " 2 : 1" is
+// imported, and compared to a locked value, as "2:1".
+func normalizedSetting(k string, raw json.RawMessage) json.RawMessage {
+	var s string
+	if k != "mix" || json.Unmarshal(raw, &s) != nil {
+		return normalizedRaw(raw)
+	}
+
+	m, err := parseMix(s)
+	if err != nil {
+		return normalizedRaw(raw)
+	}
+
+	b, err := json.Marshal(m.String())
+	if err != nil {
+		return normalizedRaw(raw)
+	}
+
+	return b
+}
+
+// Describes the result of an import for the log.
+func importSummary(skipped []string) string {
+	if len(skipped) == 0 {
+		return "Imported the shared settings"
+	}
+
+	return fmt.Sprintf("Imported the shared settings, except for %v, which your administrator has locked", strings.Join(skipped, ", "))
+}
+
+// Imports the settings from the -import flag and saves them, before the UI or
+// any subcommand runs.
+func (app *App) importFlag() {
+	values, err := readSettings(flagImport)
+	if err != nil {
+		log.Fatalf("failed to import settings: %v", err.Error())
+	}
+
+	skipped, err := app.importSettings(values)
+	if err != nil {
+		log.Fatalf("failed to import settings: %v", err.Error())
+	}
+
+	log.Println(importSummary(skipped))
+
+	if err := app.saveConfig(); err != nil {
+		log.Printf("failed to save imported settings: %v", err.Error())
+	}
+}
+
+// Runs the export subcommand, which prints the shareable settings code, or
+// the settings file with -json. Returns the process exit code.
+func (app *App) exportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print a JSON settings file instead of a settings code")
+	_ = fs.Parse(args)
+
+	if *asJSON {
+		b, err := app.settingsJSON()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+
+		_, _ = os.Stdout.Write(b)
+
+		return 0
+	}
+
+	code, err := app.settingsCode()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	fmt.Fprintln(os.Stdout, code)
+
+	return 0
+}
+
+// Shows a dialog with the shareable code for the current settings, which can
+// be copied or saved as a JSON file, and imports settings from a pasted code
+// or a JSON file.
+func (app *App) shareDialog() {
+	win := fltk.NewWindow(460, 230, "Share Settings")
+	code := fltk.NewOutput(10, 30, 440, 25, "Code for the current settings:")
+	copyCode := fltk.NewButton(10, 65, 120, 30, "&Copy")
+	save := fltk.NewButton(140, 65, 120, 30, "&Save JSON...")
+	paste := fltk.NewInput(10, 135, 440, 25, "Paste a code to import:")
+	load := fltk.NewButton(10, 180, 120, 30, "&Load JSON...")
+	imp := fltk.NewButton(140, 180, 120, 30, "&Import")
+	closeWin := fltk.NewButton(330, 180, 120, 30, "Close")
+	win.End()
+	win.SetModal()
+
+	code.SetAlign(fltk.ALIGN_TOP_LEFT)
+	paste.SetAlign(fltk.ALIGN_TOP_LEFT)
+	code.SetTooltip("Only the generation settings are shared, never any passwords.")
+	paste.SetTooltip("Settings locked by your administrator are left as they are.")
+
+	updateCode := func() {
+		c, err := app.settingsCode()
+		if err != nil {
+			c = err.Error()
+		}
+
+		code.SetValue(c)
+	}
+	updateCode()
+
+	apply := func(values map[string]json.RawMessage, err error) {
+		if err != nil {
+			fltk.MessageBox("Error", err.Error())
+			return
+		}
+
+		old := *app.conf
+		skipped, err := app.importSettings(values)
+		if err != nil {
+			fltk.MessageBox("Error", err.Error())
+			return
+		}
+
+		if old.Lang != app.conf.Lang || old.Mix != app.conf.Mix {
+			app.initDice()
+		}
+
+		app.syncWidgets()
+		app.settingsChanged()
+		paste.SetValue("")
+		updateCode()
+		app.ui.log.SetValue(importSummary(skipped))
+	}
+
+	copyCode.SetCallback(func() {
+		if err := clipboard.WriteAll(code.Value()); err != nil {
+			fltk.MessageBox("Error", fmt.Sprintf("failed to copy the code to the clipboard: %v", err.Error()))
+		}
+	})
+
+	save.SetCallback(func() {
+		p, ok := fltk.ChooseFile("Save the settings as JSON", "*.json", "diceware-settings.json", false)
+		if !ok || p == "" {
+			return
+		}
+
+		b, err := app.settingsJSON()
+		if err == nil {
+			err = os.WriteFile(p, b, 0o644)
+		}
+
+		if err != nil {
+			fltk.MessageBox("Error", err.Error())
+			return
+		}
+
+		app.ui.log.SetValue(fmt.Sprintf("Saved the shared settings to %v", p))
+	})
+
+	load.SetCallback(func() {
+		p, ok := fltk.ChooseFile("Choose a settings file to import", "*.json", "", false)
+		if !ok || p == "" {
+			return
+		}
+
+		b, err := os.ReadFile(p)
+		if err != nil {
+			apply(nil, err)
+			return
+		}
+
+		apply(parseSettingsFile(b))
+	})
+
+	imp.SetCallback(func() { apply(parseSettings(paste.Value())) })
+	closeWin.SetCallback(func() { win.Hide() })
+
+	win.Show()
+}
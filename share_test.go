@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Returns a settings code with a valid checksum for the given prefix, such as
+// gfd1, and JSON payload.
+func testSettingsCode(prefix, payload string) string {
+	p := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return fmt.Sprintf("%v.%v.%v", prefix, p, settingsChecksum(prefix, p))
+}
+
+func TestParseSettingsVersion(t *testing.T) {
+	tests := []struct {
+		prefix string
+		err    string
+	}{
+		{"gfd1", ""},
+		{"gfd0", "invalid settings code version"},
+		{"gfd-1", "invalid settings code version"},
+		{"gfd01", "invalid settings code version"},
+		{"gfd+1", "invalid settings code version"},
+		{"gfd", "invalid settings code version"},
+		{"gfd2", "newer version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			values, err := parseSettings(testSettingsCode(tt.prefix, `{"w":5}`))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err.Error())
+				}
+
+				if string(values["wordCount"]) != "5" {
+					t.Errorf("got wordCount %s, want 5", values["wordCount"])
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseSettingsFileVersion(t *testing.T) {
+	for _, v := range []int{-1, 0, SETTINGS_CODE_VERSION + 1} {
+		f := fmt.Sprintf(`{"format": %q, "version": %v, "settings": {"wordCount": 5}}`, SETTINGS_FILE_FORMAT, v)
+		if _, err := parseSettings(f); err == nil {
+			t.Errorf("version %v: expected an error", v)
+		}
+	}
+
+	f := fmt.Sprintf(`{"format": %q, "version": %v, "settings": {"wordCount": 5}}`, SETTINGS_FILE_FORMAT, SETTINGS_CODE_VERSION)
+	if _, err := parseSettings(f); err != nil {
+		t.Errorf("unexpected error: %v", err.Error())
+	}
+}
+
+// Imported mixes are stored the way the mix selector stores them.
+func TestImportSettingsNormalizesMix(t *testing.T) {
+	tests := []struct {
+		name string
+		mix  string
+		want string
+	}{
+		{"compact", "2:1", "2:1"},
+		{"spaces", " 2 : 1", "2:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testApp()
+			app.langs = []Language{{Name: DEFAULT_LANG}}
+			values := map[string]json.RawMessage{"mix": json.RawMessage(fmt.Sprintf("%q", tt.mix))}
+			if _, err := app.importSettings(values); err != nil {
+				t.Fatal(err)
+			}
+
+			if app.conf.Mix != tt.want {
+				t.Errorf("got mix %q, want %q", app.conf.Mix, tt.want)
+			}
+		})
+	}
+
+	// a locked mix isn't reported as skipped when the import only differs in
+	// spacing
+	app := testApp()
+	app.langs = []Language{{Name: DEFAULT_LANG}}
+	app.conf.Mix = "2:1"
+	app.layers.locked["mix"] = "policy.json"
+	skipped, err := app.importSettings(map[string]json.RawMessage{"mix": json.RawMessage(`" 2 : 1"`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(skipped) != 0 {
+		t.Errorf("got skipped %v, want none", skipped)
+	}
+}
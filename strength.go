@@ -0,0 +1,901 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The strength checker is modeled on zxcvbn: the password is broken up into
+// every recognizable pattern (dictionary words, keyboard walks, dates, repeats,
+// sequences), the number of guesses needed for each is estimated, and the
+// sequence of patterns that is cheapest for an attacker to guess determines
+// the strength of the whole password.
+//
+// Passwords must never be logged; nothing in here calls Log or Logf.
+
+const (
+	// Passwords longer than this are truncated before pattern matching, since
+	// matching is quadratic in the password length.
+	maxCheckLength = 100
+	// Guesses per character for parts of the password that don't match any
+	// pattern.
+	bruteforceCardinality = 10
+	// Guesses are penalized for every additional pattern in a sequence, so
+	// that the checker doesn't prefer splitting passwords into lots of tiny
+	// patterns.
+	minGuessesBeforeGrowingSequence = 10000
+	// Patterns that are only a part of the password need at least this many
+	// guesses.
+	minSubmatchGuessesSingleChar = 10
+	minSubmatchGuessesMultiChar  = 50
+	// Years closer than this to the current year are assumed equally likely.
+	minYearSpace = 20
+	// Sequences such as "acegi" can step by up to this many characters.
+	maxSequenceDelta = 5
+	// Dictionary words shorter than this are ignored.
+	minDictionaryLength = 3
+)
+
+// A small list of extremely common passwords, ordered by popularity, which are
+// checked in addition to the word lists.
+var commonPasswords = []string{
+	"123456", "password", "123456789", "12345678", "12345", "qwerty", "1234567",
+	"111111", "123123", "abc123", "1234567890", "password1", "iloveyou",
+	"000000", "qwerty123", "admin", "letmein", "welcome", "monkey", "dragon",
+	"sunshine", "princess", "football", "baseball", "master", "shadow",
+	"superman", "trustno1", "passw0rd", "login", "starwars", "hello",
+}
+
+// Reversible l33t substitutions: each symbol and the letters it could stand in
+// for.
+var l33tTable = map[rune][]rune{
+	'4': {'a'}, '@': {'a'}, '8': {'b'}, '(': {'c'}, '{': {'c'}, '[': {'c'},
+	'<': {'c'}, '3': {'e'}, '6': {'g'}, '9': {'g'}, '1': {'i', 'l'},
+	'!': {'i'}, '|': {'i', 'l'}, '7': {'l', 't'}, '0': {'o'}, '$': {'s'},
+	'5': {'s'}, '+': {'t'}, '%': {'x'}, '2': {'z'},
+}
+
+// Rows of a qwerty keyboard, unshifted and shifted, used to detect keyboard
+// walks.
+var qwertyRows = [][2]string{
+	{"`1234567890-=", "~!@#$%^&*()_+"},
+	{"qwertyuiop[]\\", "QWERTYUIOP{}|"},
+	{"asdfghjkl;'", "ASDFGHJKL:\""},
+	{"zxcvbnm,./", "ZXCVBNM<>?"},
+}
+
+// Horizontal offset of each keyboard row, in a slanted grid where a key at
+// (x, y) borders (x-1, y), (x+1, y), (x, y-1), (x+1, y-1), (x-1, y+1) and
+// (x, y+1).
+var qwertyRowOffsets = []int{0, 1, 1, 1}
+
+// The neighbor directions in the slanted grid, in order.
+var slantedDirections = [][2]int{{-1, 0}, {1, 0}, {0, -1}, {1, -1}, {-1, 1}, {0, 1}}
+
+var (
+	yearRegexp          = regexp.MustCompile(`^(19\d\d|20\d\d)$`)
+	dateWithSepRegexp   = regexp.MustCompile(`^(\d{1,4})([\s/\\_.-])(\d{1,2})([\s/\\_.-])(\d{1,4})$`)
+	dateSplitsForLength = map[int][][2]int{
+		4: {{1, 2}, {2, 3}},
+		5: {{1, 3}, {2, 3}},
+		6: {{1, 2}, {2, 4}, {4, 5}},
+		7: {{1, 3}, {2, 3}, {4, 5}, {4, 6}},
+		8: {{2, 4}, {4, 6}},
+	}
+)
+
+// A recognized pattern within a password.
+type match struct {
+	// dictionary, spatial, repeat, sequence, date or bruteforce
+	pattern string
+	// the first and last rune indices of the match, inclusive
+	i, j    int
+	token   string
+	guesses float64
+	// human-readable explanation that is shown to the user; never logged
+	desc string
+}
+
+// The result of checking a password's strength.
+type Strength struct {
+	Guesses float64 `json:"guesses"`
+	// log10 of the guesses, which is easier to read for strong passwords
+	GuessesLog10 float64 `json:"guessesLog10"`
+	// 0 (too guessable) to 4 (very unguessable), same as zxcvbn
+	Score      int         `json:"score"`
+	CrackTimes []CrackTime `json:"crackTimes"`
+	// human-readable descriptions of the patterns that an attacker would
+	// guess, in order
+	Patterns []string `json:"patterns"`
+	// the number of times the password was seen in the breach data, if it's
+	// configured
+	Breaches int64 `json:"breaches"`
+	// the patterns that an attacker would guess, in order
+	sequence []match
+}
+
+// The estimated time to crack a password for one kind of attack.
+type CrackTime struct {
+	Scenario string  `json:"scenario"`
+	Seconds  float64 `json:"seconds"`
+	Display  string  `json:"display"`
+}
+
+// The keyboard layout as a lookup from each character to its key's position,
+// and whether the character is shifted.
+type keyboard struct {
+	keys    map[rune][2]int
+	shifted map[rune]bool
+	grid    map[[2]int]bool
+	// number of characters and average number of neighbors per key, used to
+	// estimate guesses for keyboard walks
+	startingPositions float64
+	averageDegree     float64
+}
+
+// Builds the qwerty keyboard layout.
+func newKeyboard() *keyboard {
+	kb := &keyboard{
+		keys:    map[rune][2]int{},
+		shifted: map[rune]bool{},
+		grid:    map[[2]int]bool{},
+	}
+
+	for y, row := range qwertyRows {
+		shifted := []rune(row[1])
+		for i, r := range []rune(row[0]) {
+			p := [2]int{qwertyRowOffsets[y] + i, y}
+			kb.keys[r] = p
+			kb.keys[shifted[i]] = p
+			kb.shifted[shifted[i]] = true
+			kb.grid[p] = true
+		}
+	}
+
+	degrees := 0
+	for p := range kb.grid {
+		for _, d := range slantedDirections {
+			if kb.grid[[2]int{p[0] + d[0], p[1] + d[1]}] {
+				degrees++
+			}
+		}
+	}
+
+	kb.startingPositions = float64(len(kb.keys))
+	kb.averageDegree = float64(degrees) / float64(len(kb.grid))
+
+	return kb
+}
+
+// Returns the direction from key a to key b, or -1 if they aren't neighbors.
+func (kb *keyboard) direction(a, b rune) int {
+	pa, ok := kb.keys[a]
+	if !ok {
+		return -1
+	}
+
+	pb, ok := kb.keys[b]
+	if !ok {
+		return -1
+	}
+
+	for i, d := range slantedDirections {
+		if pa[0]+d[0] == pb[0] && pa[1]+d[1] == pb[1] {
+			return i
+		}
+	}
+
+	return -1
+}
+
+var qwerty = newKeyboard()
+
+// Returns n choose k.
+func nCk(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+
+	if k == 0 {
+		return 1
+	}
+
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r *= float64(n)
+		r /= float64(d)
+		n--
+	}
+
+	return r
+}
+
+// Returns the number of ways that the letters of the token could have been
+// capitalized the way they are.
+func uppercaseVariations(token []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range token {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 1
+	}
+
+	// capitalizing the first letter, the last letter, or everything is common
+	if lower == 0 ||
+		(upper == 1 && unicode.IsUpper(token[0])) ||
+		(upper == 1 && unicode.IsUpper(token[len(token)-1])) {
+		return 2
+	}
+
+	v := 0.0
+	for i := 1; i <= min(upper, lower); i++ {
+		v += nCk(upper+lower, i)
+	}
+
+	return v
+}
+
+// Returns the number of ways that the letters of the token could have been
+// substituted for their l33t equivalents.
+func l33tVariations(token []rune, subs map[rune]rune) float64 {
+	v := 1.0
+	for symbol, letter := range subs {
+		s, u := 0, 0
+		for _, r := range token {
+			if r == symbol {
+				s++
+			} else if unicode.ToLower(r) == letter {
+				u++
+			}
+		}
+
+		if s == 0 || u == 0 {
+			v *= 2
+			continue
+		}
+
+		p := 0.0
+		for i := 1; i <= min(s, u); i++ {
+			p += nCk(s+u, i)
+		}
+
+		v *= p
+	}
+
+	return v
+}
+
+// Builds the dictionary that passwords are checked against: the common
+// passwords ranked by popularity, then every word in the word lists, where a
+// word's guesses are the size of the smallest list that contains it.
+func buildDictionary(lists ...[]string) map[string]float64 {
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	dict := map[string]float64{}
+	for _, list := range lists {
+		for _, w := range list {
+			w = strings.ToLower(w)
+			if _, ok := dict[w]; !ok {
+				dict[w] = float64(len(list))
+			}
+		}
+	}
+
+	for i, w := range commonPasswords {
+		dict[w] = float64(i + 1)
+	}
+
+	return dict
+}
+
+// Finds every dictionary word in the password, including reversed words and
+// words with l33t substitutions.
+func dictionaryMatches(pw []rune, dict map[string]float64) []match {
+	lower := make([]rune, len(pw))
+	for i, r := range pw {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	matches := []match{}
+	for i := range lower {
+		for j := i + minDictionaryLength - 1; j < len(lower) && j-i < maxWordLength; j++ {
+			token := pw[i : j+1]
+			word := string(lower[i : j+1])
+			if rank, ok := dict[word]; ok {
+				matches = append(matches, match{
+					pattern: "dictionary",
+					i:       i, j: j,
+					token:   string(token),
+					guesses: rank * uppercaseVariations(token),
+					desc:    fmt.Sprintf("dictionary word %q", word),
+				})
+			}
+
+			reversed := []rune(word)
+			for a, b := 0, len(reversed)-1; a < b; a, b = a+1, b-1 {
+				reversed[a], reversed[b] = reversed[b], reversed[a]
+			}
+
+			if rank, ok := dict[string(reversed)]; ok && string(reversed) != word {
+				matches = append(matches, match{
+					pattern: "dictionary",
+					i:       i, j: j,
+					token:   string(token),
+					guesses: rank * uppercaseVariations(token) * 2,
+					desc:    fmt.Sprintf("reversed dictionary word %q", string(reversed)),
+				})
+			}
+
+			for _, c := range unl33t(lower[i : j+1]) {
+				if rank, ok := dict[c.word]; ok {
+					matches = append(matches, match{
+						pattern: "dictionary",
+						i:       i, j: j,
+						token:   string(token),
+						guesses: rank * uppercaseVariations(token) * l33tVariations(token, c.subs),
+						desc:    fmt.Sprintf("dictionary word %q with l33t substitutions", c.word),
+					})
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+// A possible reading of a l33t token, and which symbol stood in for which
+// letter.
+type l33tCandidate struct {
+	word string
+	subs map[rune]rune
+}
+
+// Returns every way of reading the lower-case token with its l33t symbols
+// replaced by letters, up to a limit.
+func unl33t(token []rune) []l33tCandidate {
+	const limit = 64
+
+	candidates := []l33tCandidate{{subs: map[rune]rune{}}}
+	for _, r := range token {
+		letters, ok := l33tTable[r]
+		if !ok {
+			for i := range candidates {
+				candidates[i].word += string(r)
+			}
+
+			continue
+		}
+
+		next := []l33tCandidate{}
+	expand:
+		for _, c := range candidates {
+			for _, l := range letters {
+				// a symbol can only stand for one letter within a token
+				if prev, ok := c.subs[r]; ok && prev != l {
+					continue
+				}
+
+				subs := map[rune]rune{r: l}
+				for k, v := range c.subs {
+					subs[k] = v
+				}
+
+				next = append(next, l33tCandidate{word: c.word + string(l), subs: subs})
+				if len(next) >= limit {
+					break expand
+				}
+			}
+		}
+
+		candidates = next
+	}
+
+	result := []l33tCandidate{}
+	for _, c := range candidates {
+		if len(c.subs) > 0 {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// Finds keyboard walks such as "qwerty" or "zaq1@WSX".
+func spatialMatches(pw []rune) []match {
+	matches := []match{}
+	for i := 0; i < len(pw)-2; {
+		j := i
+		turns := 0
+		last := -1
+		for j+1 < len(pw) {
+			d := qwerty.direction(pw[j], pw[j+1])
+			if d == -1 {
+				break
+			}
+
+			if d != last {
+				turns++
+				last = d
+			}
+
+			j++
+		}
+
+		if j-i+1 < 3 {
+			i++
+			continue
+		}
+
+		shifted := 0
+		for _, r := range pw[i : j+1] {
+			if qwerty.shifted[r] {
+				shifted++
+			}
+		}
+
+		l := j - i + 1
+		guesses := 0.0
+		for k := 2; k <= l; k++ {
+			for t := 1; t <= min(turns, k-1); t++ {
+				guesses += nCk(k-1, t-1) * qwerty.startingPositions * math.Pow(qwerty.averageDegree, float64(t))
+			}
+		}
+
+		if shifted > 0 {
+			unshifted := l - shifted
+			if unshifted == 0 {
+				guesses *= 2
+			} else {
+				v := 0.0
+				for k := 1; k <= min(shifted, unshifted); k++ {
+					v += nCk(shifted+unshifted, k)
+				}
+				guesses *= v
+			}
+		}
+
+		matches = append(matches, match{
+			pattern: "spatial",
+			i:       i, j: j,
+			token:   string(pw[i : j+1]),
+			guesses: guesses,
+			desc:    fmt.Sprintf("keyboard walk with %v turns", turns),
+		})
+
+		i = j
+	}
+
+	return matches
+}
+
+// Finds repeated characters and repeated groups of characters, such as "aaa"
+// or "abcabc".
+func repeatMatches(pw []rune) []match {
+	matches := []match{}
+	for i := range pw {
+		bestLen, bestBase := 0, 0
+		for b := 1; i+2*b <= len(pw); b++ {
+			k := 1
+			for i+(k+1)*b <= len(pw) && string(pw[i+k*b:i+(k+1)*b]) == string(pw[i:i+b]) {
+				k++
+			}
+
+			if k >= 2 && k*b >= 3 && k*b > bestLen {
+				bestLen, bestBase = k*b, b
+			}
+		}
+
+		if bestLen == 0 {
+			continue
+		}
+
+		base := pw[i : i+bestBase]
+		count := bestLen / bestBase
+		matches = append(matches, match{
+			pattern: "repeat",
+			i:       i, j: i + bestLen - 1,
+			token:   string(pw[i : i+bestLen]),
+			guesses: estimateGuesses(base).Guesses * float64(count),
+			desc:    fmt.Sprintf("%q repeated %v times", string(base), count),
+		})
+	}
+
+	return matches
+}
+
+// Finds sequences of evenly spaced characters, such as "abcd", "9753" or
+// "zyx".
+func sequenceMatches(pw []rune) []match {
+	matches := []match{}
+	for i := 0; i < len(pw)-2; {
+		delta := int(pw[i+1]) - int(pw[i])
+		j := i + 1
+		for j+1 < len(pw) && int(pw[j+1])-int(pw[j]) == delta {
+			j++
+		}
+
+		if delta == 0 || abs(delta) > maxSequenceDelta || j-i+1 < 3 {
+			i++
+			continue
+		}
+
+		first := pw[i]
+		var base float64
+		switch {
+		case strings.ContainsRune("aAzZ019", first):
+			base = 4
+		case unicode.IsDigit(first):
+			base = 10
+		default:
+			base = 26
+		}
+
+		if delta < 0 {
+			base *= 2
+		}
+
+		matches = append(matches, match{
+			pattern: "sequence",
+			i:       i, j: j,
+			token:   string(pw[i : j+1]),
+			guesses: base * float64(j-i+1),
+			desc:    "sequence of evenly spaced characters",
+		})
+
+		i = j
+	}
+
+	return matches
+}
+
+// Returns the number of years an attacker would have to guess to reach the
+// given year.
+func yearSpace(year int) float64 {
+	return math.Max(math.Abs(float64(year-time.Now().Year())), minYearSpace)
+}
+
+// Expands a 2-digit year into a 4-digit year.
+func fourDigitYear(y int) int {
+	switch {
+	case y > 99:
+		return y
+	case y > 50:
+		return 1900 + y
+	default:
+		return 2000 + y
+	}
+}
+
+// Returns the year of a date made up of three numbers in any common order
+// (day-month-year, month-day-year or year-month-day), or 0 if it isn't a valid
+// date.
+func dateYear(a, b, c int, aLen, cLen int) int {
+	valid := func(y, m, d int) bool {
+		return y >= 1000 && y <= 2050 && m >= 1 && m <= 12 && d >= 1 && d <= 31
+	}
+
+	if aLen == 4 || (aLen == 2 && cLen != 4) {
+		y := fourDigitYear(a)
+		if valid(y, b, c) {
+			return y
+		}
+	}
+
+	if cLen == 4 || cLen == 2 {
+		y := fourDigitYear(c)
+		if valid(y, b, a) || valid(y, a, b) {
+			return y
+		}
+	}
+
+	return 0
+}
+
+// Finds years and dates, with or without separators.
+func dateMatches(pw []rune) []match {
+	matches := []match{}
+	for i := range pw {
+		for j := i + 3; j < len(pw) && j-i < 10; j++ {
+			token := string(pw[i : j+1])
+
+			if yearRegexp.MatchString(token) {
+				y, _ := strconv.Atoi(token)
+				matches = append(matches, match{
+					pattern: "date",
+					i:       i, j: j,
+					token:   token,
+					guesses: yearSpace(y),
+					desc:    "recent year",
+				})
+
+				continue
+			}
+
+			if sm := dateWithSepRegexp.FindStringSubmatch(token); sm != nil && sm[2] == sm[4] {
+				a, _ := strconv.Atoi(sm[1])
+				b, _ := strconv.Atoi(sm[3])
+				c, _ := strconv.Atoi(sm[5])
+				if y := dateYear(a, b, c, len(sm[1]), len(sm[5])); y != 0 {
+					matches = append(matches, match{
+						pattern: "date",
+						i:       i, j: j,
+						token:   token,
+						guesses: yearSpace(y) * 365 * 4,
+						desc:    "date",
+					})
+				}
+
+				continue
+			}
+
+			splits, ok := dateSplitsForLength[len(token)]
+			if !ok || strings.IndexFunc(token, func(r rune) bool { return !unicode.IsDigit(r) }) != -1 {
+				continue
+			}
+
+			for _, split := range splits {
+				a, _ := strconv.Atoi(token[:split[0]])
+				b, _ := strconv.Atoi(token[split[0]:split[1]])
+				c, _ := strconv.Atoi(token[split[1]:])
+				if y := dateYear(a, b, c, split[0], len(token)-split[1]); y != 0 {
+					matches = append(matches, match{
+						pattern: "date",
+						i:       i, j: j,
+						token:   token,
+						guesses: yearSpace(y) * 365,
+						desc:    "date",
+					})
+
+					break
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+// Returns the absolute value of an int.
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+
+	return i
+}
+
+// Returns n!
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+
+	return f
+}
+
+// Estimates the number of guesses needed to crack the password, using only the
+// patterns that don't need a dictionary.
+func estimateGuesses(pw []rune) Strength {
+	return mostGuessableSequence(pw, append(append(append(
+		spatialMatches(pw),
+		sequenceMatches(pw)...),
+		dateMatches(pw)...),
+		repeatMatches(pw)...))
+}
+
+// Checks the strength of a password against the dictionary, and estimates the
+// time it would take each attacker to crack it.
+func checkStrength(password string, dict map[string]float64, attackers []Attacker) Strength {
+	pw := []rune(password)
+	if len(pw) > maxCheckLength {
+		pw = pw[:maxCheckLength]
+	}
+
+	matches := append(append(append(append(
+		dictionaryMatches(pw, dict),
+		spatialMatches(pw)...),
+		sequenceMatches(pw)...),
+		dateMatches(pw)...),
+		repeatMatches(pw)...)
+
+	s := mostGuessableSequence(pw, matches)
+	s.GuessesLog10 = math.Log10(s.Guesses)
+	s.Score = score(s.Guesses)
+	s.CrackTimes = crackTimes(s.Guesses, attackers)
+	s.Patterns = make([]string, len(s.sequence))
+	for i, m := range s.sequence {
+		s.Patterns[i] = fmt.Sprintf("%q: %v (%.3g guesses)", m.token, m.desc, m.guesses)
+	}
+
+	return s
+}
+
+// Finds the sequence of non-overlapping matches (with bruteforce filling in
+// the gaps) that needs the fewest guesses to crack. The guesses for a sequence
+// of l matches are l! times the product of each match's guesses, plus a
+// penalty for longer sequences.
+func mostGuessableSequence(pw []rune, matches []match) Strength {
+	n := len(pw)
+	if n == 0 {
+		return Strength{Guesses: 1}
+	}
+
+	byEnd := make([][]match, n)
+	for _, m := range matches {
+		if m.guesses < 1 {
+			m.guesses = 1
+		}
+
+		if m.j-m.i+1 < n {
+			floor := float64(minSubmatchGuessesMultiChar)
+			if m.j == m.i {
+				floor = minSubmatchGuessesSingleChar
+			}
+
+			m.guesses = math.Max(m.guesses, floor)
+		}
+
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	// for each end position k and sequence length l: the last match, the
+	// product of guesses, and the total guesses of the best sequence
+	type entry struct {
+		m  match
+		pi float64
+		g  float64
+	}
+
+	optimal := make([]map[int]entry, n)
+	for k := range optimal {
+		optimal[k] = map[int]entry{}
+	}
+
+	update := func(m match, l int) {
+		k := m.j
+		pi := m.guesses
+		if l > 1 {
+			pi *= optimal[m.i-1][l-1].pi
+		}
+
+		g := factorial(l)*pi + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+		for cl, c := range optimal[k] {
+			if cl <= l && c.g <= g {
+				return
+			}
+		}
+
+		optimal[k][l] = entry{m: m, pi: pi, g: g}
+	}
+
+	bruteforce := func(i, j int) match {
+		guesses := math.Pow(bruteforceCardinality, float64(j-i+1))
+		if j-i+1 < n {
+			guesses = math.Max(guesses, minSubmatchGuessesMultiChar)
+		}
+
+		return match{pattern: "bruteforce", i: i, j: j, token: string(pw[i : j+1]), guesses: guesses, desc: "no recognizable pattern"}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range byEnd[k] {
+			if m.i == 0 {
+				update(m, 1)
+				continue
+			}
+
+			for l := range optimal[m.i-1] {
+				update(m, l+1)
+			}
+		}
+
+		update(bruteforce(0, k), 1)
+		for i := 1; i <= k; i++ {
+			for l, e := range optimal[i-1] {
+				// consecutive bruteforce matches are always worse than one
+				if e.m.pattern == "bruteforce" {
+					continue
+				}
+
+				update(bruteforce(i, k), l+1)
+			}
+		}
+	}
+
+	bestL := 0
+	best := math.Inf(1)
+	for l, e := range optimal[n-1] {
+		if e.g < best {
+			best, bestL = e.g, l
+		}
+	}
+
+	sequence := make([]match, bestL)
+	for k, l := n-1, bestL; l > 0; l-- {
+		e := optimal[k][l]
+		sequence[l-1] = e.m
+		k = e.m.i - 1
+	}
+
+	return Strength{Guesses: best, sequence: sequence}
+}
+
+// Converts guesses into a score from 0 to 4, the same as zxcvbn.
+func score(guesses float64) int {
+	const delta = 5
+	switch {
+	case guesses < 1e3+delta:
+		return 0
+	case guesses < 1e6+delta:
+		return 1
+	case guesses < 1e8+delta:
+		return 2
+	case guesses < 1e10+delta:
+		return 3
+	}
+
+	return 4
+}
+
+// Describes a score.
+func scoreName(score int) string {
+	return []string{"too guessable", "very guessable", "somewhat guessable", "safely unguessable", "very unguessable"}[score]
+}
+
+// Formats a number of seconds as a human-readable duration.
+func formatDuration(seconds float64) string {
+	const (
+		minute  = 60
+		hour    = minute * 60
+		day     = hour * 24
+		month   = day * 31
+		year    = month * 12
+		century = year * 100
+	)
+
+	units := []struct {
+		name string
+		size float64
+	}{
+		{"year", year},
+		{"month", month},
+		{"day", day},
+		{"hour", hour},
+		{"minute", minute},
+		{"second", 1},
+	}
+
+	if seconds < 1 {
+		return "less than a second"
+	}
+
+	if seconds >= century {
+		return "centuries"
+	}
+
+	for _, u := range units {
+		if seconds >= u.size {
+			v := math.Round(seconds / u.size)
+			if v == 1 {
+				return fmt.Sprintf("1 %v", u.name)
+			}
+
+			return fmt.Sprintf("%v %vs", v, u.name)
+		}
+	}
+
+	return "less than a second"
+}
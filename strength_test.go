@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// A dictionary with a list the size of a diceware list, which includes the
+// words of the classic "correct horse battery staple" example.
+func testDictionary() map[string]float64 {
+	list := []string{"correct", "horse", "battery", "staple"}
+	for i := len(list); i < 7776; i++ {
+		list = append(list, fmt.Sprintf("filler%v", i))
+	}
+
+	return buildDictionary(list)
+}
+
+// Returns the rank of a common password in the dictionary.
+func commonRank(t *testing.T, w string) float64 {
+	t.Helper()
+
+	for i, c := range commonPasswords {
+		if c == w {
+			return float64(i + 1)
+		}
+	}
+
+	t.Fatalf("%v isn't a common password", w)
+
+	return 0
+}
+
+// Known passwords, the patterns that an attacker would guess them with, and
+// their guesses and scores.
+func TestCheckStrength(t *testing.T) {
+	dict := testDictionary()
+
+	// the guesses for one match covering the whole password
+	single := func(g float64) float64 { return g + 1 }
+
+	tests := []struct {
+		password string
+		patterns []string
+		descs    []string
+		guesses  float64
+		score    int
+	}{
+		{"password", []string{"dictionary"}, []string{`dictionary word "password"`}, single(commonRank(t, "password")), 0},
+		{"Password", []string{"dictionary"}, []string{`dictionary word "password"`}, single(commonRank(t, "password") * 2), 0},
+		{"qwerty", []string{"dictionary"}, []string{`dictionary word "qwerty"`}, single(commonRank(t, "qwerty")), 0},
+		{"drowssap", []string{"dictionary"}, []string{`reversed dictionary word "password"`}, single(commonRank(t, "password") * 2), 0},
+		{"esroh", []string{"dictionary"}, []string{`reversed dictionary word "horse"`}, single(7776 * 2), 1},
+		{"p4ssw0rd", []string{"dictionary"}, []string{`dictionary word "password" with l33t substitutions`}, single(commonRank(t, "password") * 4), 0},
+		{"1987-05-12", []string{"date"}, []string{"date"}, single(yearSpace(1987) * 365 * 4), 1},
+		{"19870512", []string{"date"}, []string{"date"}, single(yearSpace(1987) * 365), 1},
+		{"1987", []string{"date"}, []string{"recent year"}, single(yearSpace(1987)), 0},
+		{"aaaa", []string{"repeat"}, []string{`"a" repeated 4 times`}, single(11 * 4), 0},
+		{"abcabc", []string{"repeat"}, []string{`"abc" repeated 2 times`}, single(13 * 2), 0},
+		{"abcd", []string{"sequence"}, []string{"sequence of evenly spaced characters"}, single(4 * 4), 0},
+		{"9753", []string{"sequence"}, []string{"sequence of evenly spaced characters"}, single(4 * 2 * 4), 0},
+		{"zaq1@WSX", []string{"spatial"}, []string{"keyboard walk with 3 turns"}, 0, 2},
+		{"kT8#vQ2!mZ", []string{"bruteforce"}, []string{"no recognizable pattern"}, single(1e10), 3},
+		{
+			"correcthorsebatterystaple",
+			[]string{"dictionary", "dictionary", "dictionary", "dictionary"},
+			[]string{`dictionary word "correct"`, `dictionary word "horse"`, `dictionary word "battery"`, `dictionary word "staple"`},
+			24*math.Pow(7776, 4) + 1e12,
+			4,
+		},
+		{
+			"P4ssw0rd!",
+			[]string{"dictionary", "bruteforce"},
+			[]string{`dictionary word "password" with l33t substitutions`, "no recognizable pattern"},
+			// both parts are raised to the minimum for partial matches
+			2*minSubmatchGuessesMultiChar*minSubmatchGuessesMultiChar + minGuessesBeforeGrowingSequence,
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			s := checkStrength(tt.password, dict, defaultAttackers())
+
+			patterns := []string{}
+			descs := []string{}
+			for _, m := range s.sequence {
+				patterns = append(patterns, m.pattern)
+				descs = append(descs, m.desc)
+			}
+
+			if strings.Join(patterns, ",") != strings.Join(tt.patterns, ",") || strings.Join(descs, ",") != strings.Join(tt.descs, ",") {
+				t.Errorf("got patterns %q %q, want %q %q", patterns, descs, tt.patterns, tt.descs)
+			}
+
+			if tt.guesses != 0 && math.Abs(s.Guesses-tt.guesses) > tt.guesses*1e-9 {
+				t.Errorf("got %v guesses, want %v", s.Guesses, tt.guesses)
+			}
+
+			if s.Score != tt.score {
+				t.Errorf("got score %v, want %v", s.Score, tt.score)
+			}
+
+			if len(s.Patterns) != len(s.sequence) || len(s.CrackTimes) != len(defaultAttackers()) {
+				t.Errorf("got %v patterns and %v crack times", len(s.Patterns), len(s.CrackTimes))
+			}
+		})
+	}
+}
+
+func TestCheckStrengthEdgeCases(t *testing.T) {
+	dict := testDictionary()
+
+	if s := checkStrength("", dict, nil); s.Guesses != 1 || s.Score != 0 || len(s.sequence) != 0 {
+		t.Errorf("empty password: got %+v", s)
+	}
+
+	// only the first maxCheckLength characters are matched
+	long := strings.Repeat("kT8#vQ2!mZ", 20)
+	if s := checkStrength(long, dict, nil); s.sequence[len(s.sequence)-1].j != maxCheckLength-1 {
+		t.Errorf("long password matched up to %v", s.sequence[len(s.sequence)-1].j)
+	}
+}
+
+func TestUnl33t(t *testing.T) {
+	words := func(cs []l33tCandidate) []string {
+		result := []string{}
+		for _, c := range cs {
+			result = append(result, c.word)
+		}
+
+		return result
+	}
+
+	tests := []struct {
+		token string
+		want  []string
+	}{
+		{"p4ssw0rd", []string{"password"}},
+		{"password", []string{}},
+		{"1337", []string{"ieel", "ieet", "leel", "leet"}},
+		// the same symbol stands for the same letter everywhere in a token
+		{"1o1", []string{"ioi", "lol"}},
+		{"|1|", []string{"iii", "ili", "lil", "lll"}},
+	}
+
+	for _, tt := range tests {
+		got := words(unl33t([]rune(tt.token)))
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%v: got %q, want %q", tt.token, got, tt.want)
+		}
+	}
+}
+
+// No token can expand into more than 64 readings, however many symbols are
+// ambiguous.
+func TestUnl33tLimit(t *testing.T) {
+	saved := l33tTable
+	t.Cleanup(func() { l33tTable = saved })
+
+	l33tTable = map[rune][]rune{}
+	for k, v := range saved {
+		l33tTable[k] = v
+	}
+
+	for _, r := range "#^&*" {
+		l33tTable[r] = []rune("abcdefgh")
+	}
+
+	if got := len(unl33t([]rune("#^&*"))); got != 64 {
+		t.Errorf("got %v candidates, want 64", got)
+	}
+}
+
+func TestVariations(t *testing.T) {
+	upper := []struct {
+		token string
+		want  float64
+	}{
+		{"password", 1},
+		{"Password", 2},
+		{"passworD", 2},
+		{"PASSWORD", 2},
+		{"pAssword", nCk(8, 1)},
+		{"pAsSword", nCk(8, 1) + nCk(8, 2)},
+		{"1234", 1},
+	}
+
+	for _, tt := range upper {
+		if got := uppercaseVariations([]rune(tt.token)); got != tt.want {
+			t.Errorf("uppercase %v: got %v, want %v", tt.token, got, tt.want)
+		}
+	}
+
+	l33t := []struct {
+		token string
+		subs  map[rune]rune
+		want  float64
+	}{
+		{"p4ssw0rd", map[rune]rune{'4': 'a', '0': 'o'}, 4},
+		// with both "a" and "4", either could have been substituted
+		{"4a", map[rune]rune{'4': 'a'}, nCk(2, 1)},
+		{"44aa", map[rune]rune{'4': 'a'}, nCk(4, 1) + nCk(4, 2)},
+	}
+
+	for _, tt := range l33t {
+		if got := l33tVariations([]rune(tt.token), tt.subs); got != tt.want {
+			t.Errorf("l33t %v: got %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestSpatialMatches(t *testing.T) {
+	walk := func(l, turns int) float64 {
+		g := 0.0
+		for k := 2; k <= l; k++ {
+			for tt := 1; tt <= min(turns, k-1); tt++ {
+				g += nCk(k-1, tt-1) * qwerty.startingPositions * math.Pow(qwerty.averageDegree, float64(tt))
+			}
+		}
+
+		return g
+	}
+
+	tests := []struct {
+		password string
+		token    string
+		guesses  float64
+	}{
+		{"qwerty", "qwerty", walk(6, 1)},
+		{"xxasdfxx", "asdf", walk(4, 1)},
+		// every character is shifted
+		{"QWERTY", "QWERTY", walk(6, 1) * 2},
+		// a straight diagonal is a single turn
+		{"zaq1", "zaq1", walk(4, 1)},
+		{"qwedc", "qwedc", walk(5, 2)},
+	}
+
+	for _, tt := range tests {
+		matches := spatialMatches([]rune(tt.password))
+		if len(matches) != 1 || matches[0].token != tt.token || math.Abs(matches[0].guesses-tt.guesses) > 1e-6 {
+			t.Errorf("%v: got %+v, want %q with %v guesses", tt.password, matches, tt.token, tt.guesses)
+		}
+	}
+
+	if matches := spatialMatches([]rune("qa")); len(matches) != 0 {
+		t.Errorf("2 keys aren't a walk, got %+v", matches)
+	}
+}
+
+func TestDateYear(t *testing.T) {
+	tests := []struct {
+		a, b, c    int
+		aLen, cLen int
+		want       int
+	}{
+		{1987, 5, 12, 4, 2, 1987},
+		{12, 5, 1987, 2, 4, 1987},
+		{5, 12, 1987, 1, 4, 1987},
+		{87, 5, 12, 2, 2, 1987},
+		{12, 5, 87, 2, 2, 1987},
+		{7, 5, 1, 2, 1, 2007},
+		{1987, 13, 12, 4, 2, 0},
+		{1987, 5, 32, 4, 2, 0},
+		{3000, 5, 12, 4, 2, 0},
+	}
+
+	for _, tt := range tests {
+		if got := dateYear(tt.a, tt.b, tt.c, tt.aLen, tt.cLen); got != tt.want {
+			t.Errorf("%v %v %v: got %v, want %v", tt.a, tt.b, tt.c, got, tt.want)
+		}
+	}
+}
+
+// The sequence that needs the fewest guesses wins, and gaps are filled with
+// bruteforce.
+func TestMostGuessableSequence(t *testing.T) {
+	pw := []rune("abcdef")
+
+	// one match for the whole password beats two halves
+	whole := match{pattern: "dictionary", i: 0, j: 5, token: "abcdef", guesses: 100}
+	first := match{pattern: "dictionary", i: 0, j: 2, token: "abc", guesses: 60}
+	second := match{pattern: "dictionary", i: 3, j: 5, token: "def", guesses: 60}
+	s := mostGuessableSequence(pw, []match{first, second, whole})
+	if len(s.sequence) != 1 || s.sequence[0].token != "abcdef" || s.Guesses != 101 {
+		t.Errorf("got %v guesses with %+v", s.Guesses, s.sequence)
+	}
+
+	// unless the whole match is much worse
+	whole.guesses = 1e12
+	s = mostGuessableSequence(pw, []match{first, second, whole})
+	if want := 2*60*60 + float64(minGuessesBeforeGrowingSequence); len(s.sequence) != 2 || s.Guesses != want {
+		t.Errorf("got %v guesses with %+v, want %v", s.Guesses, s.sequence, want)
+	}
+
+	// the rest of the password is bruteforced
+	s = mostGuessableSequence(pw, []match{first})
+	if len(s.sequence) != 2 || s.sequence[1].pattern != "bruteforce" || s.sequence[1].token != "def" {
+		t.Errorf("got %+v", s.sequence)
+	}
+
+	// without any matches, the whole password is bruteforced
+	s = mostGuessableSequence(pw, nil)
+	if len(s.sequence) != 1 || s.sequence[0].pattern != "bruteforce" || s.Guesses != 1e6+1 {
+		t.Errorf("got %v guesses with %+v", s.Guesses, s.sequence)
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		guesses float64
+		want    int
+	}{
+		{1, 0},
+		{1e3, 0},
+		{1e3 + 5, 1},
+		{1e6, 1},
+		{1e6 + 5, 2},
+		{1e8, 2},
+		{1e8 + 5, 3},
+		{1e10, 3},
+		{1e10 + 5, 4},
+		{math.Inf(1), 4},
+	}
+
+	for _, tt := range tests {
+		if got := score(tt.guesses); got != tt.want {
+			t.Errorf("score(%v) = %v, want %v", tt.guesses, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	const day = 24 * 3600
+
+	tests := []struct {
+		seconds float64
+		want    string
+	}{
+		{0, "less than a second"},
+		{0.5, "less than a second"},
+		{1, "1 second"},
+		{59, "59 seconds"},
+		{60, "1 minute"},
+		{90, "2 minutes"},
+		{3600, "1 hour"},
+		{day, "1 day"},
+		{31 * day, "1 month"},
+		{12 * 31 * day, "1 year"},
+		{99 * 12 * 31 * day, "99 years"},
+		{100 * 12 * 31 * day, "centuries"},
+		{math.Inf(1), "centuries"},
+	}
+
+	for _, tt := range tests {
+		if got := formatDuration(tt.seconds); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+// The check subcommand prints a report for every password, as text or JSON.
+func TestReportStrength(t *testing.T) {
+	app := testApp()
+	app.dict = testDictionary()
+	passwords := []string{"password", "correcthorsebatterystaple"}
+
+	out := new(bytes.Buffer)
+	if code := app.reportStrength(out, passwords, false); code != 0 {
+		t.Fatalf("exited with %v", code)
+	}
+
+	for _, want := range []string{
+		"score: 0/4 (too guessable)\n",
+		"score: 4/4 (very unguessable)\n",
+		`pattern: "password": dictionary word "password"`,
+		"offline attack, bcrypt (10k/second): less than a second\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output doesn't contain %q:\n%v", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if code := app.reportStrength(out, passwords, true); code != 0 {
+		t.Fatalf("exited with %v", code)
+	}
+
+	results := []Strength{}
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[0].Score != 0 || results[1].Score != 4 || len(results[1].Patterns) != 4 {
+		t.Errorf("got %+v", results)
+	}
+}
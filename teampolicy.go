@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Character classes that a team policy can require in every generated
+// password.
+const (
+	CLASS_LOWER  = "lower"
+	CLASS_UPPER  = "upper"
+	CLASS_DIGIT  = "digit"
+	CLASS_SYMBOL = "symbol"
+)
+
+// Names of the two word lists of each language in a team policy's allowed
+// word lists, such as "en/simple". A bare language such as "en" allows both.
+const (
+	WORD_LIST_SIMPLE   = "simple"
+	WORD_LIST_EXTENDED = "extended"
+)
+
+// TeamPolicy is a password policy published by a security team, which the
+// settings are checked against. Unlike an admin policy, it doesn't lock
+// anything; the app only reports where the settings fall short of it.
+type TeamPolicy struct {
+	// Shown along with violations, such as "ACME VPN passphrases"
+	Name string `json:"name"`
+	// The least entropy, in bits, that the settings must give
+	MinEntropy float64 `json:"minEntropy"`
+	// If set, the separator must be one of these
+	AllowedSeparators []string `json:"allowedSeparators"`
+	// Character classes that every generated password must contain: lower,
+	// upper, digit or symbol
+	RequiredCharClasses []string `json:"requiredCharClasses"`
+	// If set, only these word lists may be used, such as "en" for both English
+	// lists or "de/simple" for only the simple German list
+	AllowedWordLists []string `json:"allowedWordLists"`
+}
+
+// Reads a team policy file. Unknown fields are rejected, so that a typo can't
+// silently disable a rule.
+func loadTeamPolicy(p string) (*TeamPolicy, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("team policy not readable at %v: %v", p, err.Error())
+	}
+
+	var policy TeamPolicy
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("team policy %v failed to parse: %v", p, err.Error())
+	}
+
+	for _, class := range policy.RequiredCharClasses {
+		switch class {
+		case CLASS_LOWER, CLASS_UPPER, CLASS_DIGIT, CLASS_SYMBOL:
+		default:
+			return nil, fmt.Errorf("team policy %v requires unknown character class %q; use lower, upper, digit or symbol", p, class)
+		}
+	}
+
+	if policy.Name == "" {
+		policy.Name = p
+	}
+
+	return &policy, nil
+}
+
+// Loads the team policy that the config points to, if any. Called whenever the
+// config is loaded.
+func (app *App) loadTeamPolicy() {
+	app.teamPolicy = nil
+	if app.conf.TeamPolicy == "" {
+		return
+	}
+
+	policy, err := loadTeamPolicy(app.conf.TeamPolicy)
+	if err != nil {
+		app.warn("%v; settings aren't checked against it", err.Error())
+		return
+	}
+
+	app.teamPolicy = policy
+}
+
+// Returns the character classes that every password generated from the pools
+// is guaranteed to contain. Every password ends with a digit and a symbol, and
+// starts with a capitalized word.
+func guaranteedClasses(pools [][]string, s string) map[string]bool {
+	classes := map[string]bool{CLASS_DIGIT: true, CLASS_SYMBOL: true}
+
+	// every word must have the class, in at least one position
+	allHave := func(pool []string, skipFirst bool, has func(rune) bool) bool {
+		for _, w := range pool {
+			if skipFirst {
+				_, size := utf8.DecodeRuneInString(w)
+				w = w[size:]
+			}
+
+			if strings.IndexFunc(w, has) < 0 {
+				return false
+			}
+		}
+
+		return len(pool) > 0
+	}
+
+	// only the first rune of the password is capitalized, so every word that
+	// can come first must start with a letter that has an upper case
+	if len(pools) > 0 && len(pools[0]) > 0 {
+		classes[CLASS_UPPER] = true
+		for _, w := range pools[0] {
+			r, _ := utf8.DecodeRuneInString(w)
+			if !unicode.IsUpper(unicode.ToUpper(r)) {
+				classes[CLASS_UPPER] = false
+				break
+			}
+		}
+	}
+
+	for i, pool := range pools {
+		if allHave(pool, i == 0, unicode.IsLower) {
+			classes[CLASS_LOWER] = true
+		}
+	}
+
+	// the separator is in every password with more than one word
+	if len(pools) > 1 {
+		if strings.IndexFunc(s, unicode.IsLower) >= 0 {
+			classes[CLASS_LOWER] = true
+		}
+
+		if strings.IndexFunc(s, unicode.IsUpper) >= 0 {
+			classes[CLASS_UPPER] = true
+		}
+	}
+
+	return classes
+}
+
+// Returns the word lists that the current settings draw from, such as
+// "en/simple".
+func (app *App) wordListsInUse() []string {
+	m := app.mix
+	lists := []string{}
+	if m.Union || m.Simple > 0 {
+		lists = append(lists, fmt.Sprintf("%v/%v", app.lang, WORD_LIST_SIMPLE))
+	}
+
+	if m.needsComplex() && len(app.words.Complex) > 0 {
+		lists = append(lists, fmt.Sprintf("%v/%v", app.lang, WORD_LIST_EXTENDED))
+	}
+
+	return lists
+}
+
+// Returns true if the word list, such as "en/simple", is allowed by any of the
+// entries.
+func wordListAllowed(list string, allowed []string) bool {
+	lang, _, _ := strings.Cut(list, "/")
+	for _, a := range allowed {
+		if a == list || a == lang {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks the current settings against the team policy, and returns every way
+// in which they don't comply.
+func (app *App) checkTeamPolicy(policy *TeamPolicy) []string {
+	violations := []string{}
+
+	if bits := app.entropy(); bits < policy.MinEntropy {
+		violations = append(violations, fmt.Sprintf("the settings give %.1f bits of entropy, below the minimum of %v bits", bits, policy.MinEntropy))
+	}
+
+	if len(policy.AllowedSeparators) > 0 {
+		allowed := false
+		for _, s := range policy.AllowedSeparators {
+			if s == app.conf.Separator {
+				allowed = true
+			}
+		}
+
+		if !allowed {
+			quoted := make([]string, len(policy.AllowedSeparators))
+			for i, s := range policy.AllowedSeparators {
+				quoted[i] = fmt.Sprintf("%q", s)
+			}
+
+			violations = append(violations, fmt.Sprintf("separator %q isn't allowed; use one of %v", app.conf.Separator, strings.Join(quoted, ", ")))
+		}
+	}
+
+	if len(policy.RequiredCharClasses) > 0 {
+		classes := guaranteedClasses(app.mix.pools(&app.words, app.conf.WordCount), app.conf.Separator)
+		for _, class := range policy.RequiredCharClasses {
+			if !classes[class] {
+				violations = append(violations, fmt.Sprintf("generated passwords don't always contain a %v character", class))
+			}
+		}
+	}
+
+	if len(policy.AllowedWordLists) > 0 {
+		for _, list := range app.wordListsInUse() {
+			if !wordListAllowed(list, policy.AllowedWordLists) {
+				violations = append(violations, fmt.Sprintf("word list %v isn't allowed; use %v", list, strings.Join(policy.AllowedWordLists, ", ")))
+			}
+		}
+	}
+
+	return violations
+}
+
+// Start of each team policy violation in the UI log.
+const violationPrefix = "<font color=\"red\">Violates "
+
+// Shows any team policy violations of the current settings above the current
+// contents of the UI log.
+func (app *App) showViolations() {
+	if app.teamPolicy == nil {
+		return
+	}
+
+	// replace the violations from the last check, if the log still shows them
+	kept := []string{}
+	for _, line := range strings.SplitAfter(app.ui.log.Value(), "<br>") {
+		if !strings.HasPrefix(line, violationPrefix) {
+			kept = append(kept, line)
+		}
+	}
+
+	sb := new(strings.Builder)
+	for _, v := range app.checkTeamPolicy(app.teamPolicy) {
+		fmt.Fprintf(sb, "%v%v: %v</font><br>", violationPrefix, html.EscapeString(app.teamPolicy.Name), html.EscapeString(v))
+	}
+
+	app.ui.log.SetValue(sb.String() + strings.Join(kept, ""))
+}
+
+// The output of the policy check subcommand with -json.
+type policyCheckOutput struct {
+	Policy     string   `json:"policy"`
+	Name       string   `json:"name"`
+	Compliant  bool     `json:"compliant"`
+	Violations []string `json:"violations"`
+}
+
+// Runs the policy subcommand. "policy check" checks the current settings
+// against the team policy, and exits non-zero if they don't comply. Returns the
+// process exit code.
+func (app *App) policyCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: policy check [-p policy.json] [-json]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("policy check", flag.ExitOnError)
+	p := fs.String("p", app.conf.TeamPolicy, "the team policy file to check against, instead of the one in the config")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	_ = fs.Parse(args[1:])
+
+	if *p == "" {
+		fmt.Fprintln(os.Stderr, "no team policy is configured; set teamPolicy in the config, or pass -team-policy or -p")
+		return 2
+	}
+
+	policy, err := loadTeamPolicy(*p)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+
+	violations := app.checkTeamPolicy(policy)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(policyCheckOutput{
+			Policy:     *p,
+			Name:       policy.Name,
+			Compliant:  len(violations) == 0,
+			Violations: violations,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode output: %v\n", err.Error())
+			return 2
+		}
+	} else if len(violations) == 0 {
+		fmt.Fprintf(os.Stdout, "the settings comply with %v\n", policy.Name)
+	} else {
+		fmt.Fprintf(os.Stdout, "the settings don't comply with %v:\n", policy.Name)
+		for _, v := range violations {
+			fmt.Fprintf(os.Stdout, "- %v\n", v)
+		}
+	}
+
+	if len(violations) > 0 {
+		return 1
+	}
+
+	return 0
+}
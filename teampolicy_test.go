@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestGuaranteedClasses(t *testing.T) {
+	tests := []struct {
+		name  string
+		pools [][]string
+		s     string
+		upper bool
+		lower bool
+	}{
+		{"letters", [][]string{{"acorn", "badge"}, {"cabin"}}, " ", true, true},
+		{"first word starts with a digit", [][]string{{"acorn", "4ward"}, {"cabin"}}, " ", false, true},
+		{"first word starts with a symbol", [][]string{{"-acorn", "badge"}}, " ", false, true},
+		{"only later words start with a digit", [][]string{{"acorn"}, {"4ward"}}, " ", true, true},
+		{"upper case separator", [][]string{{"4ward"}, {"8ball"}}, "X", true, true},
+		{"capitalized word has no other lower case", [][]string{{"a123"}}, " ", true, false},
+		{"no words", [][]string{{}}, " ", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classes := guaranteedClasses(tt.pools, tt.s)
+			if classes[CLASS_UPPER] != tt.upper {
+				t.Errorf("upper: got %v, want %v", classes[CLASS_UPPER], tt.upper)
+			}
+
+			if classes[CLASS_LOWER] != tt.lower {
+				t.Errorf("lower: got %v, want %v", classes[CLASS_LOWER], tt.lower)
+			}
+
+			if !classes[CLASS_DIGIT] || !classes[CLASS_SYMBOL] {
+				t.Errorf("every password has a digit and a symbol")
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/pwiecz/go-fltk"
+)
+
+// Name of the directory (inside of the XDG config dirs, or the portable dir)
+// that contains theme files, one theme per file, i.e.
+// ~/.config/go-fltk-diceware/themes/solarized.json
+const THEMES_DIR = "themes"
+
+// Names of the built-in themes.
+const (
+	THEME_LIGHT         = "light"
+	THEME_DARK          = "dark"
+	THEME_HIGH_CONTRAST = "high-contrast"
+)
+
+// The window colors that FLTK starts with, which themes without their own
+// window colors use.
+var (
+	defaultWindowBackground = [3]uint8{192, 192, 192}
+	defaultWindowForeground = [3]uint8{0, 0, 0}
+)
+
+// Theme is a named set of colors for the UI. Colors are written as "#rrggbb";
+// colors that are left empty keep FLTK's default for each widget.
+type Theme struct {
+	// The name shown in the theme selector and stored in the config
+	Name string `json:"name"`
+	// The color of widget labels
+	Text string `json:"text"`
+	// The background of inputs, selectors and the log
+	InputBackground string `json:"inputBackground"`
+	// The background of selected text and menu items
+	Selection string `json:"selection"`
+	// The background of the window and dialogs
+	WindowBackground string `json:"windowBackground"`
+	// The text of the window and dialogs, including typed text
+	WindowForeground string `json:"windowForeground"`
+	// The background of the Generate button; the input background if empty
+	Accent string `json:"accent"`
+}
+
+// Returns the themes that are always available. The auto theme has no colors of
+// its own; it's resolved to the light or dark theme when it's applied.
+func builtinThemes() []Theme {
+	return []Theme{
+		{Name: THEME_AUTO},
+		{Name: THEME_LIGHT},
+		{
+			Name:             THEME_DARK,
+			Text:             "#9f9f9f",
+			InputBackground:  "#202020",
+			Selection:        "#afafaf",
+			WindowBackground: "#282828",
+			WindowForeground: "#e6e6e6",
+		},
+		{
+			Name:             THEME_HIGH_CONTRAST,
+			Text:             "#ffffff",
+			InputBackground:  "#000000",
+			Selection:        "#ffff00",
+			WindowBackground: "#000000",
+			WindowForeground: "#ffffff",
+			Accent:           "#0000c0",
+		},
+	}
+}
+
+// Parses a "#rrggbb" color into its red, green and blue components.
+func parseRGB(s string) ([3]uint8, error) {
+	hex, ok := strings.CutPrefix(strings.TrimSpace(s), "#")
+	if !ok || len(hex) != 6 {
+		return [3]uint8{}, fmt.Errorf("color %q must be written as #rrggbb", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return [3]uint8{}, fmt.Errorf("color %q must be written as #rrggbb", s)
+	}
+
+	return [3]uint8{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// Returns the FLTK color for a "#rrggbb" color, or def if it's empty or
+// invalid.
+func themeColor(s string, def fltk.Color) fltk.Color {
+	if s == "" {
+		return def
+	}
+
+	rgb, err := parseRGB(s)
+	if err != nil {
+		return def
+	}
+
+	return fltk.Color(uint32(rgb[0])<<24 | uint32(rgb[1])<<16 | uint32(rgb[2])<<8)
+}
+
+// Checks that the theme has a name and that all of its colors are valid.
+func validateTheme(t Theme) error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("theme has no name")
+	}
+
+	for _, c := range []string{t.Text, t.InputBackground, t.Selection, t.WindowBackground, t.WindowForeground, t.Accent} {
+		if c == "" {
+			continue
+		}
+
+		if _, err := parseRGB(c); err != nil {
+			return fmt.Errorf("theme %q: %v", t.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Reads a theme file in any config format. The theme is named after the file
+// if it doesn't have a name.
+func loadThemeFile(p string) (Theme, error) {
+	var t Theme
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return t, fmt.Errorf("theme file not readable at %v: %v", p, err.Error())
+	}
+
+	values, err := decodeConfig(p, b)
+	if err != nil {
+		return t, fmt.Errorf("theme file %v failed to parse: %v", p, err.Error())
+	}
+
+	b, _ = json.Marshal(values)
+	if err := json.Unmarshal(b, &t); err != nil {
+		return t, fmt.Errorf("theme file %v failed to parse: %v", p, err.Error())
+	}
+
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+
+	if err := validateTheme(t); err != nil {
+		return t, fmt.Errorf("theme file %v: %v", p, err.Error())
+	}
+
+	return t, nil
+}
+
+// Returns every available theme: the built-in themes, then theme files from
+// the system config dirs, the user's config dir (or the portable dir), and
+// finally the themes in the config. Later themes replace earlier ones with the
+// same name, so built-in themes can be customized.
+func (app *App) findThemes() []Theme {
+	dirs := []string{}
+	for i := len(xdg.ConfigDirs) - 1; i >= 0; i-- {
+		dirs = append(dirs, filepath.Join(xdg.ConfigDirs[i], APP_NAME, THEMES_DIR))
+	}
+
+	if app.portableDir != "" {
+		dirs = append(dirs, filepath.Join(app.portableDir, THEMES_DIR))
+	} else if xdg.ConfigHome != "" {
+		dirs = append(dirs, filepath.Join(xdg.ConfigHome, APP_NAME, THEMES_DIR))
+	}
+
+	themes := builtinThemes()
+	add := func(t Theme) {
+		if t.Name == THEME_AUTO {
+			log.Printf("ignoring user-defined theme %v, since the name is reserved; customize %v and %v instead", THEME_AUTO, THEME_LIGHT, THEME_DARK)
+			return
+		}
+
+		for i := range themes {
+			if themes[i].Name == t.Name {
+				themes[i] = t
+				return
+			}
+		}
+
+		themes = append(themes, t)
+	}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		names := []string{}
+		for _, entry := range entries {
+			for _, ext := range configExts {
+				if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ext) {
+					names = append(names, entry.Name())
+				}
+			}
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			t, err := loadThemeFile(filepath.Join(dir, name))
+			if err != nil {
+				log.Println(err.Error())
+				continue
+			}
+
+			add(t)
+		}
+	}
+
+	for _, t := range app.conf.Themes {
+		add(t)
+	}
+
+	return themes
+}
+
+// Returns the theme with the given name.
+func findTheme(themes []Theme, name string) (Theme, bool) {
+	for _, t := range themes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+
+	return Theme{}, false
+}
+
+// Escapes a label for use as a menu item, since FLTK treats slashes as
+// submenus and ampersands as shortcuts.
+func menuLabel(s string) string {
+	return strings.NewReplacer("/", "\\/", "&", "&&").Replace(s)
+}
+
+// Applies the selected theme to the UI, falling back to the light theme if it
+// can't be found. The auto theme uses the light or dark theme, depending on the
+// desktop's preference.
+func (app *App) applyTheme() {
+	name := app.conf.Theme
+	if name == THEME_AUTO {
+		app.watchDesktopTheme()
+		name = THEME_LIGHT
+		if app.desktopDark {
+			name = THEME_DARK
+		}
+	}
+
+	t, ok := findTheme(app.themes, name)
+	if !ok {
+		log.Printf("theme %v not found, using %v", name, THEME_LIGHT)
+		t, _ = findTheme(app.themes, THEME_LIGHT)
+	}
+
+	app.ui.theme(t)
+}
+
+// Populates the theme selector with all available themes, and applies a theme
+// as soon as it's selected. Can be called again when the themes change.
+func (app *App) themeCB() {
+	app.ui.themes.Clear()
+	for i, t := range app.themes {
+		name := t.Name
+		app.ui.themes.Add(menuLabel(name), func() {
+			if name == app.conf.Theme {
+				return
+			}
+
+			app.conf.Theme = name
+			app.settingsChanged()
+			app.applyTheme()
+		})
+
+		if name == app.conf.Theme {
+			app.ui.themes.SetValue(i)
+		}
+	}
+}
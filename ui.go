@@ -17,17 +17,6 @@ const (
 	HEIGHT_LANDSCAPE = 100
 )
 
-// Positioning (x,y,w,h) for fltk elements
-type pos struct {
-	X int
-	Y int
-	W int
-	H int
-	// A reference to the UI is needed in order to perform the translation
-	// according to the ui's specs
-	ui *UI
-}
-
 // Buttons, inputs, widgets, etc that need to be repositioned in a responsive
 // manner.
 type UI struct {
@@ -35,29 +24,21 @@ type UI struct {
 
 	menu *fltk.MenuBar // hidden menu bar for shortcut keys
 
-	dark  *fltk.CheckButton // dark mode checkbox
-	extra *fltk.CheckButton // "use extra words" checkbox
-	max   *fltk.Input       // max output length
-	min   *fltk.Input       // min output length
-	out   *fltk.Input       // generated output input field
-	sep   *fltk.Input       // separator character input field
-	wc    *fltk.Input       // word count input field
-	log   *fltk.HelpView    // shows word count and generated word length
-	gen   *fltk.Button      // generate button
-
-	// winp   pos // main window position
-	darkp  pos // dark mode checkbox position
-	extrap pos // "use extra words" checkbox position
-	maxp   pos // max output length position
-	minp   pos // min output length position
-	outp   pos // generated output input field position
-	sepp   pos // separator character input field position
-	wcp    pos // word count input field position
-	logp   pos // shows word count and generated word length (position)
-	genp   pos // generate button position
-
-	portrait        bool // portrait mode or landscape mode
-	darkModeChanged bool // if true, prompts to restart after changing dark mode will not show
+	themes *fltk.Choice      // color theme selector
+	mix    *fltk.InputChoice // how words are drawn from the simple/extended lists
+	lang   *fltk.Choice      // word list language selector
+	max    *fltk.Input       // max output length
+	min    *fltk.Input       // min output length
+	out    *fltk.Input       // generated output input field
+	sep    *fltk.Input       // separator character input field
+	wc     *fltk.Input       // word count input field
+	log    *fltk.HelpView    // shows word count and generated word length
+	gen    *fltk.Button      // generate button
+
+	portrait bool   // if the window started in portrait mode, for its initial size
+	layout   string // the name of the layout in use
+
+	defaultColors []widgetColors // each themed widget's colors before any theme was applied
 }
 
 // isPortrait returns true if the screen is taller than it is wide. It returns
@@ -76,33 +57,6 @@ func isPortrait() (bool, error) {
 	return true, nil
 }
 
-// Translates the widget's width/height from the original 100 or 150px base
-// width/height to the window's current width/height
-func (ui *UI) tr(i int, winw int, winh int, useHeight bool) int {
-	if ui.portrait {
-		if useHeight {
-			return int(math.Round((float64(i) / float64(HEIGHT_PORTRAIT)) * float64(winh)))
-		} else {
-			return int(math.Round((float64(i) / float64(WIDTH_PORTRAIT)) * float64(winw)))
-		}
-	} else {
-		if useHeight {
-			return int(math.Round((float64(i) / float64(HEIGHT_LANDSCAPE)) * float64(winh)))
-		} else {
-			return int(math.Round((float64(i) / float64(WIDTH_LANDSCAPE)) * float64(winw)))
-		}
-	}
-}
-
-// Translate converts a predefined position into a scaled position based on
-// the latest width & height of the window.
-func (p *pos) Translate(winw, winh int) {
-	p.X = p.ui.tr(p.X, winw, winh, false)
-	p.Y = p.ui.tr(p.Y, winw, winh, true)
-	p.W = p.ui.tr(p.W, winw, winh, false)
-	p.H = p.ui.tr(p.H, winw, winh, true)
-}
-
 // Initializes the UI for the app. Call this once, only after the app config has
 // been loaded.
 func (app *App) initUI() {
@@ -133,10 +87,11 @@ func (app *App) initUI() {
 	}
 
 	// initialize all buttons and widgets
-	app.ui.win = fltk.NewWindow(winw, winh, "Diceware Password Generator FLTK")
+	app.ui.win = fltk.NewWindow(winw, winh, app.title())
 	app.ui.menu = fltk.NewMenuBar(0, 0, 0, 0)
-	app.ui.dark = fltk.NewCheckButton(0, 0, 0, 0, "&Dark Mode")
-	app.ui.extra = fltk.NewCheckButton(0, 0, 0, 0, "&Extra Words")
+	app.ui.themes = fltk.NewChoice(0, 0, 0, 0, "&Theme")
+	app.ui.mix = fltk.NewInputChoice(0, 0, 0, 0, "&Extra Words")
+	app.ui.lang = fltk.NewChoice(0, 0, 0, 0, "&Language")
 	app.ui.max = fltk.NewInput(0, 0, 0, 0, "&Max Length")
 	app.ui.min = fltk.NewInput(0, 0, 0, 0, "Mi&n Length")
 	app.ui.out = fltk.NewInput(0, 0, 0, 0, "&Output")
@@ -146,39 +101,84 @@ func (app *App) initUI() {
 	app.ui.gen = fltk.NewButton(0, 0, 0, 0, "&Generate")
 
 	// propagate default values from config to widgets that accept them
-	app.ui.dark.SetValue(app.conf.DarkMode)
-	app.ui.extra.SetValue(app.conf.Extra)
-	app.ui.max.SetValue(fmt.Sprint(app.conf.MaxLen))
-	app.ui.min.SetValue(fmt.Sprint(app.conf.MinLen))
-	app.ui.sep.SetValue(app.conf.Separator)
-	app.ui.wc.SetValue(fmt.Sprint(app.conf.WordCount))
+	app.syncWidgets()
 
-	// app.ui.dark.SetAlign(fltk.ALIGN_TOP_LEFT)
-	// app.ui.extra.SetAlign(fltk.ALIGN_TOP_LEFT)
+	app.ui.themes.SetAlign(fltk.ALIGN_TOP_LEFT)
+	app.ui.mix.SetAlign(fltk.ALIGN_TOP_LEFT)
 	app.ui.out.SetAlign(fltk.ALIGN_TOP_LEFT)
 	app.ui.max.SetAlign(fltk.ALIGN_TOP_LEFT)
 	app.ui.min.SetAlign(fltk.ALIGN_TOP_LEFT)
 	app.ui.sep.SetAlign(fltk.ALIGN_TOP_LEFT)
 	app.ui.wc.SetAlign(fltk.ALIGN_TOP_LEFT)
+	app.ui.lang.SetAlign(fltk.ALIGN_TOP_LEFT)
 	app.ui.log.SetAlign(fltk.ALIGN_TOP_LEFT)
 
 	app.ui.log.SetLabelSize(10)
 	app.ui.log.SetLabelFont(fltk.HELVETICA)
 	app.ui.log.SetValue("Output will go here")
 
-	app.ui.dark.SetTooltip("Toggling the UI mode requires a restart, and this setting will persist to settings between app restarts.")
-	app.ui.extra.SetTooltip("If enabled, a more complex word list will be used, with significantly more dictionary words to use. This is more secure, but some words may be too difficult to work with.")
-	app.ui.max.SetTooltip("The maximum permissible number of characters to generate. Default=64")
-	app.ui.min.SetTooltip("The minimum permissible number of characters to generate. Default=20")
+	for k, w := range app.ui.settingWidgets() {
+		w.SetTooltip(settingTooltips[k])
+	}
+
 	app.ui.out.SetTooltip("Generated passwords will appear here.")
-	app.ui.sep.SetTooltip("The separator to place between generated words. Default is a space character. Multiple characters can be used.")
-	app.ui.wc.SetTooltip("The number of words to generate. This may require experimenting with min/max length. Default=3")
 	app.ui.gen.SetTooltip("Press this button to generate a password with the above settings.")
 
 	app.ui.win.Resizable(app.ui.win)
 	app.ui.win.SetXClass("gfltkdice")
 }
 
+// Tooltips for the widgets of each config value.
+var settingTooltips = map[string]string{
+	"theme":     "The color theme, which is applied immediately. Themes can be added to the config or as files in the config directory, i.e. ~/.config/go-fltk-diceware/themes/solarized.json",
+	"mix":       "How words are drawn from the simple and extended word lists: simple, extended, union (both lists combined), or a simple:extended ratio such as 2:1, which draws 2 simple words followed by 1 extended word. The extended list has significantly more dictionary words to use. This is more secure, but some words may be too difficult to work with.",
+	"lang":      "The language of the word lists to use. Additional languages can be added by placing word lists in the data directory, i.e. ~/.local/share/go-fltk-diceware/words/de/words-simple.txt",
+	"maxLen":    "The maximum permissible number of characters to generate. Default=64",
+	"minLen":    "The minimum permissible number of characters to generate. Default=20",
+	"separator": "The separator to place between generated words. Default is a space character. Multiple characters can be used.",
+	"wordCount": "The number of words to generate. This may require experimenting with min/max length. Default=3",
+}
+
+// A widget that shows a config value.
+type settingWidget interface {
+	Activate()
+	Deactivate()
+	SetTooltip(string)
+}
+
+// Returns the widget for each config value that has one.
+func (ui *UI) settingWidgets() map[string]settingWidget {
+	return map[string]settingWidget{
+		"theme":     ui.themes,
+		"mix":       ui.mix,
+		"lang":      ui.lang,
+		"maxLen":    ui.max,
+		"minLen":    ui.min,
+		"separator": ui.sep,
+		"wordCount": ui.wc,
+	}
+}
+
+// Shows the current config values in their widgets.
+func (app *App) syncWidgets() {
+	app.ui.mix.SetValue(app.conf.Mix)
+	app.ui.max.SetValue(fmt.Sprint(app.conf.MaxLen))
+	app.ui.min.SetValue(fmt.Sprint(app.conf.MinLen))
+	app.ui.sep.SetValue(app.conf.Separator)
+	app.ui.wc.SetValue(fmt.Sprint(app.conf.WordCount))
+	for i, lang := range app.langs {
+		if lang.Name == app.lang {
+			app.ui.lang.SetValue(i)
+		}
+	}
+
+	for i, t := range app.themes {
+		if t.Name == app.conf.Theme {
+			app.ui.themes.SetValue(i)
+		}
+	}
+}
+
 // Sizes the window to 3x the design size, which is intentionally
 // small. Use this after things have been initiated.
 func (ui *UI) upsize() {
@@ -189,126 +189,179 @@ func (ui *UI) upsize() {
 	}
 }
 
+// Layouts for each shape of window, from the narrowest to the widest. Weights
+// are in the same units as the design sizes, so the portrait and landscape
+// layouts match WIDTH_/HEIGHT_PORTRAIT and WIDTH_/HEIGHT_LANDSCAPE. Labels are
+// drawn above their widgets, in the gaps between rows.
+var uiLayouts = []breakpoint{
+	{
+		// a single column, for very tall windows
+		name:      "tall",
+		maxAspect: 0.5,
+		root: vbox(1,
+			gap(5), hbox(15, gap(5), place("out", 90), gap(5)),
+			gap(7), hbox(147, gap(5), grid(90, []float64{1}, 0, []float64{15, 15, 15, 15, 15, 15, 15}, 7,
+				place("sep", 1), place("wc", 1), place("min", 1), place("max", 1),
+				place("mix", 1), place("themes", 1), place("lang", 1),
+			), gap(5)),
+			gap(5), hbox(30, gap(5), place("log", 90), gap(5)),
+			gap(5), hbox(20, gap(5), place("gen", 90), gap(5)),
+			gap(5),
+		),
+	},
+	{
+		name:      "portrait",
+		maxAspect: 1,
+		root: vbox(1,
+			gap(5), hbox(15, gap(5), place("out", 90), gap(5)),
+			gap(5), hbox(55, gap(5), grid(90, []float64{40, 45}, 5, []float64{15, 15, 15}, 5,
+				place("sep", 1), place("wc", 1),
+				place("min", 1), place("max", 1),
+				place("mix", 1), place("themes", 1),
+			), gap(5)),
+			gap(5), hbox(15, gap(5), place("lang", 90), gap(5)),
+			gap(5), hbox(15, gap(5), place("log", 90), gap(5)),
+			gap(5), hbox(20, gap(5), place("gen", 90), gap(5)),
+			gap(5),
+		),
+	},
+	{
+		name:      "landscape",
+		maxAspect: 2.5,
+		root: vbox(1,
+			gap(5), hbox(15, gap(5), place("out", 140), gap(5)),
+			gap(5), hbox(15, gap(5), place("sep", 35), gap(5), place("wc", 30), gap(5), place("min", 35), gap(5), place("max", 25), gap(5)),
+			gap(5), hbox(15, gap(5), place("mix", 45), gap(5), place("themes", 45), gap(5), place("lang", 40), gap(5)),
+			gap(5), hbox(15, gap(5), place("log", 140), gap(5)),
+			gap(5), hbox(10, gap(5), place("gen", 140), gap(5)),
+			gap(5),
+		),
+	},
+	{
+		// every setting in a single row, for very wide windows
+		name:      "wide",
+		maxAspect: math.Inf(1),
+		root: vbox(1,
+			gap(8), hbox(18, gap(3), place("out", 94), gap(3)),
+			gap(10), hbox(18, gap(3),
+				place("sep", 10), gap(2), place("wc", 10), gap(2), place("min", 10), gap(2), place("max", 10), gap(2),
+				place("mix", 14), gap(2), place("themes", 14), gap(2), place("lang", 14), gap(3)),
+			gap(10), hbox(28, gap(3), place("log", 70), gap(2), place("gen", 22), gap(3)),
+			gap(8),
+		),
+	},
+}
+
+// A widget that is positioned by the layout.
+type layoutWidget interface {
+	Resize(x, y, w, h int)
+}
+
+// Returns the widget for each name in the layouts.
+func (ui *UI) layoutWidgets() map[string]layoutWidget {
+	return map[string]layoutWidget{
+		"themes": ui.themes,
+		"mix":    ui.mix,
+		"lang":   ui.lang,
+		"max":    ui.max,
+		"min":    ui.min,
+		"out":    ui.out,
+		"sep":    ui.sep,
+		"wc":     ui.wc,
+		"log":    ui.log,
+		"gen":    ui.gen,
+	}
+}
+
+// Returns the name of the layout that is forced with -portrait or -landscape,
+// if any.
+func forcedLayout() string {
+	switch {
+	case forcePortrait:
+		return "portrait"
+	case forceLandscape:
+		return "landscape"
+	}
+
+	return ""
+}
+
 // Resizes and repositions all components based on the window's size.
 func (ui *UI) responsive() {
-	if forceLandscape || forcePortrait {
-		return
+	name, rects := computeLayout(uiLayouts, ui.win.W(), ui.win.H(), forcedLayout())
+	if name != ui.layout {
+		log.Printf("using the %v layout", name)
+		ui.layout = name
+	}
+
+	for k, w := range ui.layoutWidgets() {
+		r := rects[k]
+		w.Resize(r.X, r.Y, r.W, r.H)
 	}
+}
 
-	winw := ui.win.W()
-	winh := ui.win.H()
+// A widget whose colors change with the theme.
+type themedWidget interface {
+	LabelColor() fltk.Color
+	Color() fltk.Color
+	SelectionColor() fltk.Color
+	SetLabelColor(fltk.Color)
+	SetColor(fltk.Color)
+	SetSelectionColor(fltk.Color)
+}
 
-	if winw > winh {
-		ui.portrait = false
-	} else {
-		ui.portrait = true
+// The colors of a widget that the theme changes.
+type widgetColors struct {
+	label     fltk.Color
+	color     fltk.Color
+	selection fltk.Color
+}
+
+// Returns every widget whose colors change with the theme.
+func (ui *UI) themedWidgets() []themedWidget {
+	return []themedWidget{ui.themes, ui.mix, ui.lang, ui.max, ui.min, ui.out, ui.sep, ui.wc, ui.log, ui.gen}
+}
+
+// Applies the theme's colors to the window and every widget, and redraws the
+// window. Can be called at any time after the UI is initialized.
+func (ui *UI) theme(t Theme) {
+	widgets := ui.themedWidgets()
+
+	// keep each widget's own default colors for the colors that a theme leaves
+	// empty; widgets differ, i.e. buttons aren't white like inputs
+	if ui.defaultColors == nil {
+		for _, w := range widgets {
+			ui.defaultColors = append(ui.defaultColors, widgetColors{w.LabelColor(), w.Color(), w.SelectionColor()})
+		}
 	}
 
-	if ui.portrait {
-		ui.darkp = pos{X: 50, Y: 65, W: 45, H: 15, ui: ui}
-		ui.extrap = pos{X: 5, Y: 65, W: 40, H: 15, ui: ui}
-		ui.genp = pos{X: 5, Y: 125, W: 90, H: 20, ui: ui}
-		ui.logp = pos{X: 5, Y: 85, W: 90, H: 35, ui: ui}
-		ui.maxp = pos{X: 50, Y: 45, W: 45, H: 15, ui: ui}
-		ui.minp = pos{X: 5, Y: 45, W: 40, H: 15, ui: ui}
-		ui.outp = pos{X: 5, Y: 5, W: 90, H: 15, ui: ui}
-		ui.sepp = pos{X: 5, Y: 25, W: 40, H: 15, ui: ui}
-		ui.wcp = pos{X: 50, Y: 25, W: 45, H: 15, ui: ui}
-	} else {
-		// landscape
-		ui.darkp = pos{X: 80, Y: 45, W: 65, H: 15, ui: ui}
-		ui.extrap = pos{X: 5, Y: 45, W: 70, H: 15, ui: ui}
-		ui.genp = pos{X: 5, Y: 85, W: 140, H: 10, ui: ui}
-		ui.logp = pos{X: 5, Y: 65, W: 140, H: 15, ui: ui}
-		ui.maxp = pos{X: 120, Y: 25, W: 25, H: 15, ui: ui}
-		ui.minp = pos{X: 80, Y: 25, W: 35, H: 15, ui: ui}
-		ui.outp = pos{X: 5, Y: 5, W: 140, H: 15, ui: ui}
-		ui.sepp = pos{X: 5, Y: 25, W: 35, H: 15, ui: ui}
-		ui.wcp = pos{X: 45, Y: 25, W: 30, H: 15, ui: ui}
+	log.Printf("applying theme %v", t.Name)
+
+	bg, err := parseRGB(t.WindowBackground)
+	if err != nil {
+		bg = defaultWindowBackground
 	}
 
-	ui.darkp.Translate(winw, winh)
-	ui.extrap.Translate(winw, winh)
-	ui.maxp.Translate(winw, winh)
-	ui.minp.Translate(winw, winh)
-	ui.outp.Translate(winw, winh)
-	ui.sepp.Translate(winw, winh)
-	ui.wcp.Translate(winw, winh)
-	ui.logp.Translate(winw, winh)
-	ui.genp.Translate(winw, winh)
-
-	ui.dark.Resize(ui.darkp.X, ui.darkp.Y, ui.darkp.W, ui.darkp.H)
-	ui.extra.Resize(ui.extrap.X, ui.extrap.Y, ui.extrap.W, ui.extrap.H)
-	ui.max.Resize(ui.maxp.X, ui.maxp.Y, ui.maxp.W, ui.maxp.H)
-	ui.min.Resize(ui.minp.X, ui.minp.Y, ui.minp.W, ui.minp.H)
-	ui.out.Resize(ui.outp.X, ui.outp.Y, ui.outp.W, ui.outp.H)
-	ui.sep.Resize(ui.sepp.X, ui.sepp.Y, ui.sepp.W, ui.sepp.H)
-	ui.wc.Resize(ui.wcp.X, ui.wcp.Y, ui.wcp.W, ui.wcp.H)
-	ui.log.Resize(ui.logp.X, ui.logp.Y, ui.logp.W, ui.logp.H)
-	ui.gen.Resize(ui.genp.X, ui.genp.Y, ui.genp.W, ui.genp.H)
-}
+	fg, err := parseRGB(t.WindowForeground)
+	if err != nil {
+		fg = defaultWindowForeground
+	}
 
-const (
-	DARK_COLOR_TEXT               fltk.Color = 0x9f9f9f00
-	DARK_COLOR_INPUT_BG           fltk.Color = 0x20202000
-	DARK_COLOR_INPUT_SELECTED_BG  fltk.Color = 0xafafaf00
-	LIGHT_COLOR_TEXT              fltk.Color = 0x20030500
-	LIGHT_COLOR_INPUT_BG          fltk.Color = 0xFFFFFF00
-	LIGHT_COLOR_INPUT_SELECTED_BG fltk.Color = 0x00008000
-)
+	fltk.SetBackgroundColor(bg[0], bg[1], bg[2])
+	fltk.SetForegroundColor(fg[0], fg[1], fg[2])
 
-var (
-	COLOR_TEXT              fltk.Color = LIGHT_COLOR_TEXT
-	COLOR_INPUT_BG          fltk.Color = LIGHT_COLOR_INPUT_BG
-	COLOR_INPUT_SELECTED_BG fltk.Color = LIGHT_COLOR_INPUT_SELECTED_BG
-)
+	for i, w := range widgets {
+		d := ui.defaultColors[i]
+		color := t.InputBackground
+		// the Generate button stands out with the accent color
+		if w == themedWidget(ui.gen) && t.Accent != "" {
+			color = t.Accent
+		}
 
-// Changes the color of various widgets/states to light or dark mode.
-func (ui *UI) theme(dark bool) {
-	if dark {
-		log.Println("dark mode activated")
-		COLOR_TEXT = DARK_COLOR_TEXT
-		COLOR_INPUT_BG = DARK_COLOR_INPUT_BG
-		COLOR_INPUT_SELECTED_BG = DARK_COLOR_INPUT_SELECTED_BG
-		fltk.SetForegroundColor(230, 230, 230)
-		fltk.SetBackgroundColor(40, 40, 40)
-	} else {
-		log.Println("light mode activated")
-		COLOR_TEXT = LIGHT_COLOR_TEXT
-		COLOR_INPUT_BG = LIGHT_COLOR_INPUT_BG
-		COLOR_INPUT_SELECTED_BG = LIGHT_COLOR_INPUT_SELECTED_BG
-		fltk.SetBackgroundColor(192, 192, 192)
-		fltk.SetForegroundColor(0, 0, 0)
-		return
+		w.SetLabelColor(themeColor(t.Text, d.label))
+		w.SetColor(themeColor(color, d.color))
+		w.SetSelectionColor(themeColor(t.Selection, d.selection))
 	}
 
-	ui.dark.SetLabelColor(COLOR_TEXT)
-	ui.extra.SetLabelColor(COLOR_TEXT)
-	ui.max.SetLabelColor(COLOR_TEXT)
-	ui.min.SetLabelColor(COLOR_TEXT)
-	ui.out.SetLabelColor(COLOR_TEXT)
-	ui.sep.SetLabelColor(COLOR_TEXT)
-	ui.wc.SetLabelColor(COLOR_TEXT)
-	ui.log.SetLabelColor(COLOR_TEXT)
-	ui.gen.SetLabelColor(COLOR_TEXT)
-
-	ui.dark.SetColor(COLOR_INPUT_BG)
-	ui.extra.SetColor(COLOR_INPUT_BG)
-	ui.max.SetColor(COLOR_INPUT_BG)
-	ui.min.SetColor(COLOR_INPUT_BG)
-	ui.out.SetColor(COLOR_INPUT_BG)
-	ui.sep.SetColor(COLOR_INPUT_BG)
-	ui.wc.SetColor(COLOR_INPUT_BG)
-	ui.log.SetColor(COLOR_INPUT_BG)
-	ui.gen.SetColor(COLOR_INPUT_BG)
-
-	ui.dark.SetSelectionColor(COLOR_INPUT_SELECTED_BG)
-	ui.extra.SetSelectionColor(COLOR_INPUT_SELECTED_BG)
-	ui.max.SetSelectionColor(COLOR_INPUT_SELECTED_BG)
-	ui.min.SetSelectionColor(COLOR_INPUT_SELECTED_BG)
-	ui.out.SetSelectionColor(COLOR_INPUT_SELECTED_BG)
-	ui.sep.SetSelectionColor(COLOR_INPUT_SELECTED_BG)
-	ui.wc.SetSelectionColor(COLOR_INPUT_SELECTED_BG)
-	ui.log.SetSelectionColor(COLOR_INPUT_SELECTED_BG)
-	ui.gen.SetSelectionColor(COLOR_INPUT_SELECTED_BG)
+	ui.win.Redraw()
 }
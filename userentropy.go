@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pwiecz/go-fltk"
+)
+
+// Number of bytes read from the underlying random source to seed the DRBG,
+// which is 384 bits as recommended for HMAC-DRBG with SHA-256.
+const drbgSeedLength = 48
+
+// hmacDRBG is an HMAC-DRBG with SHA-256, as specified in NIST SP 800-90A.
+type hmacDRBG struct {
+	k []byte
+	v []byte
+}
+
+// Instantiates the DRBG from the seed material.
+func newHMACDRBG(seed []byte) *hmacDRBG {
+	d := &hmacDRBG{
+		k: make([]byte, sha256.Size),
+		v: make([]byte, sha256.Size),
+	}
+
+	for i := range d.v {
+		d.v[i] = 0x01
+	}
+
+	d.update(seed)
+
+	return d
+}
+
+// The HMAC-DRBG update function, which mixes data into the internal state.
+func (d *hmacDRBG) update(data []byte) {
+	for _, b := range []byte{0x00, 0x01} {
+		mac := hmac.New(sha256.New, d.k)
+		mac.Write(d.v)
+		mac.Write([]byte{b})
+		mac.Write(data)
+		d.k = mac.Sum(nil)
+
+		mac = hmac.New(sha256.New, d.k)
+		mac.Write(d.v)
+		d.v = mac.Sum(nil)
+
+		if len(data) == 0 {
+			return
+		}
+	}
+}
+
+// Reseeds the DRBG with fresh entropy and optional additional input.
+func (d *hmacDRBG) reseed(entropy []byte, additional []byte) {
+	seed := make([]byte, 0, len(entropy)+len(additional))
+	seed = append(seed, entropy...)
+	seed = append(seed, additional...)
+	d.update(seed)
+}
+
+// Fills p with output from the DRBG.
+func (d *hmacDRBG) generate(p []byte) {
+	for n := 0; n < len(p); {
+		mac := hmac.New(sha256.New, d.k)
+		mac.Write(d.v)
+		d.v = mac.Sum(nil)
+		n += copy(p[n:], d.v)
+	}
+
+	d.update(nil)
+}
+
+// mixedReader is a source of randomness that combines user-supplied entropy
+// with an underlying source (normally crypto/rand). The DRBG is seeded from
+// both, and its output is XORed with fresh output from the underlying source,
+// so the result is never less random than the underlying source alone.
+type mixedReader struct {
+	mu   sync.Mutex
+	base io.Reader
+	drbg *hmacDRBG
+}
+
+// Returns a reader that mixes the user-supplied entropy into base.
+func newMixedReader(base io.Reader, userEntropy []byte) (*mixedReader, error) {
+	seed := make([]byte, drbgSeedLength, drbgSeedLength+len(userEntropy))
+	if _, err := io.ReadFull(base, seed); err != nil {
+		return nil, fmt.Errorf("failed to read from random source: %v", err.Error())
+	}
+
+	seed = append(seed, userEntropy...)
+
+	return &mixedReader{base: base, drbg: newHMACDRBG(seed)}, nil
+}
+
+// Reseeds the DRBG with fresh output from the underlying source and more
+// user-supplied entropy.
+func (m *mixedReader) addEntropy(userEntropy []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seed := make([]byte, drbgSeedLength)
+	if _, err := io.ReadFull(m.base, seed); err != nil {
+		return fmt.Errorf("failed to read from random source: %v", err.Error())
+	}
+
+	m.drbg.reseed(seed, userEntropy)
+
+	return nil
+}
+
+// Read implements io.Reader.
+func (m *mixedReader) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fresh := make([]byte, len(p))
+	if _, err := io.ReadFull(m.base, fresh); err != nil {
+		return 0, err
+	}
+
+	m.drbg.generate(p)
+	for i := range p {
+		p[i] ^= fresh[i]
+	}
+
+	return len(p), nil
+}
+
+// Hashes the contents of a file, so that it can be used as user-supplied
+// entropy.
+func hashFile(h hash.Hash, p string) (int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open entropy file %v: %v", p, err.Error())
+	}
+	defer f.Close()
+
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return n, fmt.Errorf("failed to read entropy file %v: %v", p, err.Error())
+	}
+
+	return n, nil
+}
+
+// Mixes the user-supplied entropy into the app's source of randomness for the
+// rest of this session. Nothing is saved. Entropy that is added again later
+// reseeds the same reader.
+func (app *App) mixEntropy(userEntropy []byte) error {
+	if r, ok := app.random.(*mixedReader); ok {
+		if err := r.addEntropy(userEntropy); err != nil {
+			return err
+		}
+
+		Log("mixed more user-supplied entropy into the random source for this session")
+
+		return nil
+	}
+
+	r, err := newMixedReader(app.random, userEntropy)
+	if err != nil {
+		return err
+	}
+
+	app.random = r
+	Log("mixed user-supplied entropy into the random source for this session")
+
+	return nil
+}
+
+// Shows a dialog that collects entropy from mouse movements, typed text and
+// files, and mixes it into the app's source of randomness for this session.
+func (app *App) entropyDialog() {
+	pool := sha256.New()
+	var moves, files int
+	var fileBytes int64
+
+	win := fltk.NewWindow(400, 300, "Add Entropy")
+	pad := fltk.NewBox(fltk.DOWN_BOX, 10, 10, 380, 150, "Move the mouse around in here")
+	text := fltk.NewInput(10, 185, 380, 25, "Type random text:")
+	status := fltk.NewBox(fltk.NO_BOX, 10, 215, 380, 25, "")
+	load := fltk.NewButton(10, 260, 120, 30, "&Load File...")
+	apply := fltk.NewButton(140, 260, 120, 30, "&Apply")
+	cancel := fltk.NewButton(270, 260, 120, 30, "Cancel")
+	win.End()
+	win.SetModal()
+
+	text.SetAlign(fltk.ALIGN_TOP_LEFT)
+	pad.SetTooltip("Mouse positions and their precise timing are collected while the mouse moves inside this box.")
+	text.SetTooltip("Mash the keyboard. This text is only hashed, never stored or logged.")
+	load.SetTooltip("Hash the contents of a file, such as a photo or audio recording taken just now.")
+
+	updateStatus := func() {
+		status.SetLabel(fmt.Sprintf("Collected %v mouse events, %v typed characters, %v bytes from %v files", moves, len([]rune(text.Value())), fileBytes, files))
+	}
+	updateStatus()
+
+	buf := make([]byte, 8)
+	pad.SetEventHandler(func(e fltk.Event) bool {
+		switch e {
+		case fltk.ENTER:
+			// required in order to receive move events
+			return true
+		case fltk.MOVE, fltk.DRAG:
+			for _, v := range []int64{int64(fltk.EventX()), int64(fltk.EventY()), time.Now().UnixNano()} {
+				binary.LittleEndian.PutUint64(buf, uint64(v))
+				pool.Write(buf)
+			}
+
+			moves++
+			updateStatus()
+
+			return true
+		}
+
+		return false
+	})
+
+	text.SetCallbackCondition(fltk.WhenChanged)
+	text.SetCallback(updateStatus)
+
+	load.SetCallback(func() {
+		p, ok := fltk.ChooseFile("Choose a file to hash", "*", "", false)
+		if !ok || p == "" {
+			return
+		}
+
+		n, err := hashFile(pool, p)
+		if err != nil {
+			fltk.MessageBox("Error", err.Error())
+			return
+		}
+
+		files++
+		fileBytes += n
+		updateStatus()
+	})
+
+	cancel.SetCallback(func() { win.Hide() })
+	apply.SetCallback(func() {
+		pool.Write([]byte(text.Value()))
+		text.SetValue("")
+
+		err := app.mixEntropy(pool.Sum(nil))
+		if err != nil {
+			fltk.MessageBox("Error", err.Error())
+			return
+		}
+
+		win.Hide()
+		app.ui.log.SetValue(fmt.Sprintf("Mixed %v mouse events and %v files into the random source for this session", moves, files))
+	})
+
+	win.Show()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// Known-answer tests from the NIST CAVP HMAC_DRBG vectors for SHA-256, without
+// prediction resistance, personalization string or additional input. Each
+// test instantiates the DRBG from the entropy input and nonce, optionally
+// reseeds it, generates 1024 bits twice, and checks the second output.
+func TestHMACDRBG(t *testing.T) {
+	tests := []struct {
+		name     string
+		entropy  string
+		nonce    string
+		reseed   string
+		returned string
+	}{
+		{
+			name:     "no reseed, count 0",
+			entropy:  "ca851911349384bffe89de1cbdc46e6831e44d34a4fb935ee285dd14b71a7488",
+			nonce:    "659ba96c601dc69fc902940805ec0ca8",
+			returned: "e528e9abf2dece54d47c7e75e5fe302149f817ea9fb4bee6f4199697d04d5b89d54fbb978a15b5c443c9ec21036d2460b6f73ebad0dc2aba6e624abf07745bc107694bb7547bb0995f70de25d6b29e2d3011bb19d27676c07162c8b5ccde0668961df86803482cb37ed6d5c0bb8d50cf1f50d476aa0458bdaba806f48be9dcb8",
+		},
+		{
+			name:     "no reseed, count 1",
+			entropy:  "79737479ba4e7642a221fcfd1b820b134e9e3540a35bb48ffae29c20f5418ea3",
+			nonce:    "3593259c092bef4129bc2c6c9e19f343",
+			returned: "cf5ad5984f9e43917aa9087380dac46e410ddc8a7731859c84e9d0f31bd43655b924159413e2293b17610f211e09f770f172b8fb693a35b85d3b9e5e63b1dc252ac0e115002e9bedfb4b5b6fd43f33b8e0eafb2d072e1a6fee1f159df9b51e6c8da737e60d5032dd30544ec51558c6f080bdbdab1de8a939e961e06b5f1aca37",
+		},
+		{
+			name:     "reseed, count 0",
+			entropy:  "06032cd5eed33f39265f49ecb142c511da9aff2af71203bffaf34a9ca5bd9c0d",
+			nonce:    "0e66f71edc43e42a45ad3c6fc6cdc4df",
+			reseed:   "01920a4e669ed3a85ae8a33b35a74ad7fb2a6bb4cf395ce00334a9c9a5a5d552",
+			returned: "76fc79fe9b50beccc991a11b5635783a83536add03c157fb30645e611c2898bb2b1bc215000209208cd506cb28da2a51bdb03826aaf2bd2335d576d519160842e7158ad0949d1a9ec3e66ea1b1a064b005de914eac2e9d4f2d72a8616a80225422918250ff66a41bd2f864a6a38cc5b6499dc43f7f2bd09e1e0f8f5885935124",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newHMACDRBG(append(decodeHex(t, tt.entropy), decodeHex(t, tt.nonce)...))
+			if tt.reseed != "" {
+				d.reseed(decodeHex(t, tt.reseed), nil)
+			}
+
+			out := make([]byte, 128)
+			d.generate(out)
+			d.generate(out)
+			if got := hex.EncodeToString(out); got != tt.returned {
+				t.Errorf("got %v, want %v", got, tt.returned)
+			}
+		})
+	}
+}
+
+// Counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+
+	return n, err
+}
+
+func readN(t *testing.T, r io.Reader, n int) []byte {
+	t.Helper()
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestMixedReader(t *testing.T) {
+	entropy := []byte("mouse movements")
+
+	// every read draws as many fresh bytes from the underlying source as it
+	// returns
+	base := &countingReader{r: seededReader("base")}
+	r, err := newMixedReader(base, entropy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if base.n != drbgSeedLength {
+		t.Errorf("seeding read %v bytes, want %v", base.n, drbgSeedLength)
+	}
+
+	for _, n := range []int{1, 32, 100} {
+		before := base.n
+		readN(t, r, n)
+		if base.n-before != n {
+			t.Errorf("reading %v bytes drew %v bytes from the underlying source", n, base.n-before)
+		}
+	}
+
+	// with the same seed, the output is the DRBG's output XORed with whatever
+	// the underlying source returns afterwards
+	seed := make([]byte, drbgSeedLength)
+	zeros, err := newMixedReader(io.MultiReader(bytes.NewReader(seed), bytes.NewReader(make([]byte, 64))), entropy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ones, err := newMixedReader(io.MultiReader(bytes.NewReader(seed), bytes.NewReader(bytes.Repeat([]byte{0xff}, 64))), entropy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := readN(t, zeros, 64), readN(t, ones, 64)
+	for i := range a {
+		if a[i]^b[i] != 0xff {
+			t.Fatalf("byte %v: got %x and %x, which don't differ by the underlying output", i, a[i], b[i])
+		}
+	}
+
+	// the same user entropy with different underlying output gives different
+	// results, and so does different user entropy with the same underlying
+	// output
+	outputs := map[string]string{}
+	for _, c := range []struct{ name, base, entropy string }{
+		{"base a", "a", "mouse movements"},
+		{"base b", "b", "mouse movements"},
+		{"other entropy", "a", "typed text"},
+	} {
+		r, err := newMixedReader(seededReader(c.base), []byte(c.entropy))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := hex.EncodeToString(readN(t, r, 32))
+		if other, ok := outputs[out]; ok {
+			t.Errorf("%v and %v gave the same output", other, c.name)
+		}
+
+		outputs[out] = c.name
+	}
+
+	// the underlying source running out is an error, not weaker output
+	short, err := newMixedReader(bytes.NewReader(make([]byte, drbgSeedLength+4)), entropy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := short.Read(make([]byte, 8)); err == nil {
+		t.Error("expected an error once the underlying source runs out")
+	}
+}
+
+// Adding entropy again reseeds the existing reader instead of wrapping it.
+func TestMixEntropyReseeds(t *testing.T) {
+	app := testApp()
+	app.random = seededReader("base")
+	if err := app.mixEntropy([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	r, ok := app.random.(*mixedReader)
+	if !ok {
+		t.Fatalf("got a %T, want a mixed reader", app.random)
+	}
+
+	before := *r.drbg
+	if err := app.mixEntropy([]byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	if app.random != r {
+		t.Error("adding entropy again replaced the reader")
+	}
+
+	if bytes.Equal(before.k, r.drbg.k) || bytes.Equal(before.v, r.drbg.v) {
+		t.Error("adding entropy again didn't reseed the DRBG")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Limits for config values. Anything outside of them is almost certainly a
+// mistake, and falls back to the value from the layer below.
+const (
+	MAX_WORD_COUNT  = 64
+	MAX_LENGTH      = 1024
+	MAX_SEPARATOR   = 16
+	MAX_MIN_ENTROPY = 1024
+)
+
+// Extension of the copy of a user config that had invalid values, which is
+// kept before the config is overwritten with valid values.
+const INVALID_EXT = ".invalid"
+
+// Checks that a single config value is valid on its own. Values that depend on
+// each other are checked by validateConfig.
+func validateField(c *AppConfig, key string) error {
+	switch key {
+	case "mix":
+		_, err := parseMix(c.Mix)
+		return err
+	case "lang":
+		if strings.TrimSpace(c.Lang) == "" {
+			return fmt.Errorf("lang must not be empty")
+		}
+	case "maxLen":
+		if c.MaxLen < 1 || c.MaxLen > MAX_LENGTH {
+			return fmt.Errorf("maxLen must be from 1 to %v, not %v", MAX_LENGTH, c.MaxLen)
+		}
+	case "minLen":
+		if c.MinLen < 0 || c.MinLen > MAX_LENGTH {
+			return fmt.Errorf("minLen must be from 0 to %v, not %v", MAX_LENGTH, c.MinLen)
+		}
+	case "separator":
+		if len([]rune(c.Separator)) > MAX_SEPARATOR {
+			return fmt.Errorf("separator must be at most %v characters", MAX_SEPARATOR)
+		}
+
+		if strings.IndexFunc(c.Separator, unicode.IsControl) >= 0 {
+			return fmt.Errorf("separator must not contain control characters")
+		}
+	case "wordCount":
+		if c.WordCount < 1 || c.WordCount > MAX_WORD_COUNT {
+			return fmt.Errorf("wordCount must be from 1 to %v, not %v", MAX_WORD_COUNT, c.WordCount)
+		}
+	case "attackers":
+		if len(c.Attackers) == 0 {
+			return fmt.Errorf("attackers must not be empty")
+		}
+
+		for i, a := range c.Attackers {
+			if strings.TrimSpace(a.Name) == "" {
+				return fmt.Errorf("attacker %v has no name", i+1)
+			}
+
+			if !(a.GuessesPerSecond > 0) || math.IsInf(a.GuessesPerSecond, 0) {
+				return fmt.Errorf("attacker %q must have a positive guessesPerSecond", a.Name)
+			}
+		}
+	case "theme":
+		if strings.TrimSpace(c.Theme) == "" {
+			return fmt.Errorf("theme must not be empty")
+		}
+	case "themes":
+		for _, t := range c.Themes {
+			if err := validateTheme(t); err != nil {
+				return err
+			}
+		}
+	case "minEntropy":
+		if !(c.MinEntropy >= 0) || c.MinEntropy > MAX_MIN_ENTROPY {
+			return fmt.Errorf("minEntropy must be from 0 to %v, not %v", MAX_MIN_ENTROPY, c.MinEntropy)
+		}
+	}
+
+	return nil
+}
+
+// Checks the values that depend on each other once every layer has been
+// applied, and falls back to the defaults for them if they're invalid. If
+// either value came from the user config, the user config counts as invalid,
+// since the next save drops its values.
+func (app *App) validateConfig() {
+	if app.conf.MinLen > app.conf.MaxLen {
+		d := defaultConfig()
+		app.warn("minLen %v (from %v) is greater than maxLen %v (from %v); using the default min and max length",
+			app.conf.MinLen, app.layers.sources["minLen"], app.conf.MaxLen, app.layers.sources["maxLen"])
+		if app.fromUserConfig("minLen") || app.fromUserConfig("maxLen") {
+			app.layers.invalid = true
+		}
+
+		app.conf.MinLen = d.MinLen
+		app.conf.MaxLen = d.MaxLen
+		app.layers.sources["minLen"] = SOURCE_DEFAULT
+		app.layers.sources["maxLen"] = SOURCE_DEFAULT
+	}
+}
+
+// Returns true if the current value of the setting came from the user config.
+// Sources from config files are recorded along with the file's path.
+func (app *App) fromUserConfig(key string) bool {
+	source := app.layers.sources[key]
+
+	return source == SOURCE_USER || strings.HasPrefix(source, SOURCE_USER+" ")
+}
+
+// Logs a warning about the config, which is also shown in the UI log once the
+// UI is up.
+func (app *App) warn(format string, v ...any) {
+	msg := fmt.Sprintf(format, v...)
+	log.Println(msg)
+	app.warnings = append(app.warnings, msg)
+}
+
+// Shows any pending config warnings above the current contents of the UI log.
+func (app *App) showWarnings() {
+	if len(app.warnings) == 0 {
+		return
+	}
+
+	sb := new(strings.Builder)
+	for _, w := range app.warnings {
+		fmt.Fprintf(sb, "<font color=\"red\">Warning: %v</font><br>", html.EscapeString(w))
+	}
+
+	app.warnings = nil
+	app.ui.log.SetValue(sb.String() + app.ui.log.Value())
+}
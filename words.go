@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/adrg/xdg"
+)
+
+// The language that is always available, since its word lists are embedded
+// into the binary.
+const DEFAULT_LANG = "en"
+
+// Name of the directory (inside of the XDG data dirs) that contains
+// user-provided word lists. Each language gets its own subdirectory, i.e.
+// ~/.local/share/go-fltk-diceware/words/de/words-simple.txt
+const WORDS_DIR = "words"
+
+// File names of the simple and extended word lists, both embedded and
+// user-provided.
+const (
+	SIMPLE_WORDS_FILE  = "words-simple.txt"
+	COMPLEX_WORDS_FILE = "words-complex.txt"
+)
+
+// Language is a named pair of word lists. Only the simple list is required;
+// if the extended list is missing, extended words are unavailable for this
+// language.
+type Language struct {
+	// The name shown in the language selector and stored in the config, such
+	// as "en" or "de".
+	Name string
+	// Where the word lists are read from; either the embedded content or a
+	// directory in the portable dir or the XDG data dirs.
+	fsys fs.FS
+	// Human-readable description of where the lists came from, for logging.
+	source string
+	// If false, this language has no extended word list.
+	hasComplex bool
+}
+
+// Returns every available language, sorted by name. The embedded English lists
+// are always present; user-provided lists in the portable dir (if any) and the
+// XDG data dirs take precedence over languages with the same name that are
+// found later in the search order.
+func findLanguages(portableDir string) []Language {
+	langs := map[string]Language{}
+
+	roots := []string{}
+	if portableDir != "" {
+		roots = append(roots, filepath.Join(portableDir, WORDS_DIR))
+	}
+
+	for _, dir := range append([]string{xdg.DataHome}, xdg.DataDirs...) {
+		roots = append(roots, filepath.Join(dir, APP_NAME, WORDS_DIR))
+	}
+
+	for _, root := range roots {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+
+			name := entry.Name()
+			if _, ok := langs[name]; ok {
+				continue
+			}
+
+			fsys := os.DirFS(filepath.Join(root, name))
+			if _, err := fs.Stat(fsys, SIMPLE_WORDS_FILE); err != nil {
+				log.Printf("skipping word lists in %v: missing %v", filepath.Join(root, name), SIMPLE_WORDS_FILE)
+				continue
+			}
+
+			_, err := fs.Stat(fsys, COMPLEX_WORDS_FILE)
+			langs[name] = Language{
+				Name:       name,
+				fsys:       fsys,
+				source:     filepath.Join(root, name),
+				hasComplex: err == nil,
+			}
+		}
+	}
+
+	if _, ok := langs[DEFAULT_LANG]; !ok {
+		langs[DEFAULT_LANG] = embeddedLanguage()
+	}
+
+	result := make([]Language, 0, len(langs))
+	for _, lang := range langs {
+		result = append(result, lang)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+}
+
+// Returns the default language with the word lists that are embedded into the
+// binary, which can always be loaded.
+func embeddedLanguage() Language {
+	return Language{
+		Name:       DEFAULT_LANG,
+		fsys:       content,
+		source:     "embedded",
+		hasComplex: true,
+	}
+}
+
+// Returns the language with the given name, falling back to the default
+// language if it can't be found.
+func findLanguage(langs []Language, name string) Language {
+	var fallback Language
+	for _, lang := range langs {
+		if lang.Name == name {
+			return lang
+		}
+
+		if lang.Name == DEFAULT_LANG {
+			fallback = lang
+		}
+	}
+
+	log.Printf("word lists for language %v not found, using %v", name, DEFAULT_LANG)
+
+	return fallback
+}
+
+// Loads all eligible words from the given path into memory, one word per line.
+// Works like dice.GetWords, but accepts any fs.FS so that user-provided lists
+// can be loaded from disk.
+func loadWords(fsys fs.FS, p string) ([]string, error) {
+	f, err := fsys.Open(path.Clean(p))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open word list %v: %v", p, err.Error())
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanLines)
+
+	result := []string{}
+	for scanner.Scan() {
+		w := scanner.Text()
+		if !eligible(w) {
+			continue
+		}
+
+		result = append(result, w)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read word list %v: %v", p, err.Error())
+	}
+
+	return result, nil
+}